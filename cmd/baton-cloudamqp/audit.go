@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/conductorone/baton-cloudamqp/pkg/connector"
+	"github.com/spf13/cobra"
+)
+
+// auditCmd groups commands working on audit logs written with --audit-log.
+func auditCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Work with audit logs of provisioning changes",
+	}
+
+	verifyCmd := &cobra.Command{
+		Use:   "verify AUDIT_LOG",
+		Short: "Check that an audit log was not tampered with",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			head, err := cmd.Flags().GetString("head")
+			if err != nil {
+				return err
+			}
+
+			count, lastHash, err := connector.VerifyAuditLog(args[0], head)
+			if err != nil {
+				return fmt.Errorf("audit log %s is not intact after %d entries: %w", args[0], count, err)
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Audit log %s is intact, %d entries verified.\n", args[0], count)
+			if lastHash != "" {
+				fmt.Fprintf(cmd.OutOrStdout(), "Head: %s\n", lastHash)
+			}
+
+			return nil
+		},
+	}
+	verifyCmd.Flags().String(
+		"head",
+		"",
+		"Hash printed by a previous verification, which must still be in the log so that removing entries from its end is detected",
+	)
+	cmd.AddCommand(verifyCmd)
+
+	return cmd
+}
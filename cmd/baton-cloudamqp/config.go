@@ -3,7 +3,13 @@ package main
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
+	"github.com/conductorone/baton-cloudamqp/pkg/connector"
 	"github.com/conductorone/baton-sdk/pkg/cli"
 	"github.com/spf13/cobra"
 )
@@ -12,19 +18,357 @@ import (
 type config struct {
 	cli.BaseConfig `mapstructure:",squash"` // Puts the base config options in the same place as the connector options
 
-	AccessToken string `mapstructure:"token"`
+	AccessToken         string   `mapstructure:"token"`
+	Accounts            []string `mapstructure:"account"`
+	DefinitionsFiles    []string `mapstructure:"definitions-file"`
+	ConnectionStateFile string   `mapstructure:"connection-state-file"`
+	BrokerEvents        bool     `mapstructure:"broker-events"`
+	BrokerEventsLog     string   `mapstructure:"broker-events-log"`
+	DryRun              bool     `mapstructure:"dry-run"`
+	ProtectedUsers      []string `mapstructure:"protected-user"`
+	ProtectedBrokerUser []string `mapstructure:"protected-broker-user"`
+	AuditLog            string   `mapstructure:"audit-log"`
+	TeamWriteAccess     bool     `mapstructure:"team-write-access"`
+	DefaultUserLimits   []string `mapstructure:"default-user-limit"`
+	DefaultVhostLimits  []string `mapstructure:"default-vhost-limit"`
+	InstanceConcurrency int      `mapstructure:"instance-concurrency"`
+	InstanceTimeout     string   `mapstructure:"instance-timeout"`
+	IncludeInstances    []string `mapstructure:"include-instance"`
+	ExcludeInstances    []string `mapstructure:"exclude-instance"`
+	ValidateOnly        bool     `mapstructure:"validate-only"`
 }
 
 // validateConfig is run after the configuration is loaded, and should return an error if it isn't valid.
 func validateConfig(ctx context.Context, cfg *config) error {
-	if cfg.AccessToken == "" {
+	if cfg.AccessToken == "" && len(cfg.Accounts) == 0 {
 		return fmt.Errorf("access token is missing")
 	}
 
+	if cfg.AccessToken != "" && len(cfg.Accounts) > 0 {
+		return fmt.Errorf("access token and named accounts cannot be used together")
+	}
+
+	_, err := parseAccounts(cfg.Accounts)
+	if err != nil {
+		return err
+	}
+
+	_, err = parseDefinitionsFiles(cfg.DefinitionsFiles)
+	if err != nil {
+		return err
+	}
+
+	_, err = parseProtectedBrokerUsers(cfg.ProtectedBrokerUser)
+	if err != nil {
+		return err
+	}
+
+	_, err = parseDefaultLimits(cfg.DefaultUserLimits, cfg.DefaultVhostLimits)
+	if err != nil {
+		return err
+	}
+
+	_, err = parseInstanceFilter(cfg.IncludeInstances, cfg.ExcludeInstances)
+	if err != nil {
+		return err
+	}
+
+	if cfg.InstanceConcurrency < 1 {
+		return fmt.Errorf("instance concurrency must be at least 1")
+	}
+
+	_, err = parseInstanceTimeout(cfg.InstanceTimeout)
+	if err != nil {
+		return err
+	}
+
+	if cfg.BrokerEventsLog != "" && !cfg.BrokerEvents {
+		return fmt.Errorf("broker events log requires broker events to be enabled")
+	}
+
+	if cfg.BrokerEvents && cfg.ClientID == "" {
+		return fmt.Errorf("broker events are only followed when the connector runs as a service, with a client id")
+	}
+
 	return nil
 }
 
+// namedAccount is a CloudAMQP team account synced under an account resource.
+type namedAccount struct {
+	name  string
+	token string
+}
+
+// parseAccounts reads named accounts, provided as NAME=TOKEN.
+func parseAccounts(values []string) ([]namedAccount, error) {
+	accounts := make([]namedAccount, 0, len(values))
+	seen := make(map[string]bool, len(values))
+	tokens := make(map[string]string, len(values))
+	for _, value := range values {
+		name, token, ok := strings.Cut(value, "=")
+		if !ok || name == "" || token == "" {
+			return nil, fmt.Errorf("account must be provided as NAME=TOKEN")
+		}
+
+		if strings.Contains(name, ":") {
+			return nil, fmt.Errorf("account name %q must not contain ':'", name)
+		}
+
+		if seen[name] {
+			return nil, fmt.Errorf("account %s is provided more than once", name)
+		}
+		seen[name] = true
+
+		if other, ok := tokens[token]; ok {
+			return nil, fmt.Errorf("accounts %s and %s have the same token", other, name)
+		}
+		tokens[token] = name
+
+		accounts = append(accounts, namedAccount{name: name, token: token})
+	}
+
+	return accounts, nil
+}
+
+// parseDefinitionsFiles maps instance IDs to definitions files, provided as INSTANCE_ID=PATH.
+func parseDefinitionsFiles(values []string) (map[int]string, error) {
+	files := make(map[int]string, len(values))
+	for _, value := range values {
+		instanceID, path, ok := strings.Cut(value, "=")
+		if !ok || path == "" {
+			return nil, fmt.Errorf("definitions file %q must be provided as INSTANCE_ID=PATH", value)
+		}
+
+		id, err := strconv.Atoi(instanceID)
+		if err != nil {
+			return nil, fmt.Errorf("definitions file %q has invalid instance id: %w", value, err)
+		}
+
+		if _, ok := files[id]; ok {
+			return nil, fmt.Errorf("more than one definitions file provided for instance %d", id)
+		}
+
+		files[id] = path
+	}
+
+	return files, nil
+}
+
+// parseProtectedBrokerUsers reads protected broker users, provided as USERNAME for every instance or as
+// INSTANCE_ID=USERNAME for a single one.
+func parseProtectedBrokerUsers(values []string) ([]connector.ProtectedBrokerUser, error) {
+	users := make([]connector.ProtectedBrokerUser, 0, len(values))
+	for _, value := range values {
+		instanceID, username, ok := strings.Cut(value, "=")
+		if !ok {
+			users = append(users, connector.ProtectedBrokerUser{Username: value})
+			continue
+		}
+
+		id, err := strconv.Atoi(instanceID)
+		if err != nil || username == "" {
+			return nil, fmt.Errorf("protected broker user %q must be provided as USERNAME or INSTANCE_ID=USERNAME", value)
+		}
+
+		users = append(users, connector.ProtectedBrokerUser{InstanceID: id, Username: username})
+	}
+
+	return users, nil
+}
+
+// parseDefaultLimits reads default limits of broker users and vhosts, provided as NAME=VALUE.
+func parseDefaultLimits(userValues []string, vhostValues []string) (connector.DefaultLimits, error) {
+	userLimits, err := parseLimits("broker user", userValues, cloudamqp.LimitMaxConnections, cloudamqp.LimitMaxChannels)
+	if err != nil {
+		return connector.DefaultLimits{}, err
+	}
+
+	vhostLimits, err := parseLimits("vhost", vhostValues, cloudamqp.LimitMaxConnections, cloudamqp.LimitMaxQueues)
+	if err != nil {
+		return connector.DefaultLimits{}, err
+	}
+
+	return connector.DefaultLimits{User: userLimits, Vhost: vhostLimits}, nil
+}
+
+func parseLimits(kind string, values []string, names ...string) (cloudamqp.Limits, error) {
+	limits := make(cloudamqp.Limits, len(values))
+	for _, value := range values {
+		name, rawLimit, ok := strings.Cut(value, "=")
+		if !ok {
+			return nil, fmt.Errorf("default %s limit %q must be provided as NAME=VALUE", kind, value)
+		}
+
+		if !isOneOf(name, names) {
+			return nil, fmt.Errorf("default %s limit %q must be one of %s", kind, name, strings.Join(names, ", "))
+		}
+
+		limit, err := strconv.ParseInt(rawLimit, 10, 64)
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("default %s limit %q must have a value of zero or more", kind, value)
+		}
+
+		limits[name] = limit
+	}
+
+	return limits, nil
+}
+
+// parseInstanceFilter parses include and exclude criteria of instances, each given as FIELD=VALUE.
+func parseInstanceFilter(include []string, exclude []string) (*connector.InstanceFilter, error) {
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil, nil
+	}
+
+	filter := &connector.InstanceFilter{}
+
+	err := parseInstanceCriteria(&filter.Include, include, "include")
+	if err != nil {
+		return nil, err
+	}
+
+	err = parseInstanceCriteria(&filter.Exclude, exclude, "exclude")
+	if err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+func parseInstanceCriteria(criteria *connector.InstanceCriteria, values []string, kind string) error {
+	for _, value := range values {
+		field, criterion, ok := strings.Cut(value, "=")
+		if !ok || criterion == "" {
+			return fmt.Errorf("%s instance filter %q must be FIELD=VALUE", kind, value)
+		}
+
+		switch field {
+		case "name":
+			name, err := regexp.Compile(criterion)
+			if err != nil {
+				return fmt.Errorf("%s instance filter %q has an invalid name pattern: %w", kind, value, err)
+			}
+			criteria.Names = append(criteria.Names, name)
+		case "tag":
+			criteria.Tags = append(criteria.Tags, criterion)
+		case "region":
+			criteria.Regions = append(criteria.Regions, criterion)
+		case "plan":
+			criteria.Plans = append(criteria.Plans, criterion)
+		case "engine":
+			if !isOneOf(strings.ToLower(criterion), []string{cloudamqp.BrokerEngineRabbitMQ, cloudamqp.BrokerEngineLavinMQ}) {
+				return fmt.Errorf("%s instance filter %q must name engine %s or %s", kind, value, cloudamqp.BrokerEngineRabbitMQ, cloudamqp.BrokerEngineLavinMQ)
+			}
+			criteria.Engines = append(criteria.Engines, criterion)
+		default:
+			return fmt.Errorf("%s instance filter %q must filter on name, tag, region, plan or engine", kind, value)
+		}
+	}
+
+	return nil
+}
+
+// parseInstanceTimeout parses how long the broker of a single instance may take to be fetched.
+func parseInstanceTimeout(value string) (time.Duration, error) {
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("instance timeout %q must be a positive duration, such as 2m", value)
+	}
+
+	return timeout, nil
+}
+
+func isOneOf(value string, values []string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
 // cmdFlags sets the cmdFlags required for the connector.
 func cmdFlags(cmd *cobra.Command) {
 	cmd.PersistentFlags().String("token", "", "The CloudAMQP access token used to connect to the CloudAMQP API. ($BATON_TOKEN)")
+	cmd.PersistentFlags().StringSlice(
+		"account",
+		nil,
+		"Sync a team account under an account resource, as NAME=TOKEN, in place of --token. Can be repeated. ($BATON_ACCOUNT)",
+	)
+	cmd.PersistentFlags().StringArray(
+		"definitions-file",
+		nil,
+		"Sync the broker of an instance from its exported definitions.json instead of its management API, as INSTANCE_ID=PATH. Can be repeated. ($BATON_DEFINITIONS_FILE)",
+	)
+	cmd.PersistentFlags().String(
+		"connection-state-file",
+		"",
+		"Optional file where samples of broker connections are kept across syncs, to measure how long broker users have been dormant. ($BATON_CONNECTION_STATE_FILE)",
+	)
+	cmd.PersistentFlags().Bool(
+		"broker-events",
+		false,
+		"Follow access events of the brokers through amq.rabbitmq.event while running as a service, refreshing what they changed on next sync. ($BATON_BROKER_EVENTS)",
+	)
+	cmd.PersistentFlags().String(
+		"broker-events-log",
+		"",
+		"Optional file where followed broker events are appended as JSON lines. ($BATON_BROKER_EVENTS_LOG)",
+	)
+	cmd.PersistentFlags().Bool(
+		"dry-run",
+		false,
+		"Log the requests grants and revokes would send instead of sending them. ($BATON_DRY_RUN)",
+	)
+	cmd.PersistentFlags().Bool(
+		"team-write-access",
+		false,
+		"Declare that the access tokens are allowed to invite and remove users and change roles, which validation can not probe without changing the team. ($BATON_TEAM_WRITE_ACCESS)",
+	)
+	cmd.PersistentFlags().StringSlice(
+		"default-user-limit",
+		nil,
+		"Limit set back on a broker user when its unlimited entitlement is revoked, as max-connections=VALUE or max-channels=VALUE. Can be repeated. ($BATON_DEFAULT_USER_LIMIT)",
+	)
+	cmd.PersistentFlags().StringSlice(
+		"default-vhost-limit",
+		nil,
+		"Limit set back on a vhost when its unlimited entitlement is revoked, as max-connections=VALUE or max-queues=VALUE. Can be repeated. ($BATON_DEFAULT_VHOST_LIMIT)",
+	)
+	cmd.PersistentFlags().Int(
+		"instance-concurrency",
+		connector.DefaultInstanceConcurrency,
+		"Number of instances whose brokers are fetched at once. ($BATON_INSTANCE_CONCURRENCY)",
+	)
+	cmd.PersistentFlags().String(
+		"instance-timeout",
+		connector.DefaultInstanceTimeout.String(),
+		"How long fetching the broker of a single instance may take before the instance is synced without its broker data. ($BATON_INSTANCE_TIMEOUT)",
+	)
+	cmd.PersistentFlags().StringArray(
+		"include-instance",
+		nil,
+		"Only sync instances matching FIELD=VALUE, where FIELD is name (a regular expression), tag, region, plan or engine. Can be repeated, instances must match one value of each field. ($BATON_INCLUDE_INSTANCE)",
+	)
+	cmd.PersistentFlags().StringArray(
+		"exclude-instance",
+		nil,
+		"Do not sync instances matching FIELD=VALUE, where FIELD is name (a regular expression), tag, region, plan or engine. Can be repeated. ($BATON_EXCLUDE_INSTANCE)",
+	)
+	cmd.PersistentFlags().String(
+		"audit-log",
+		"",
+		"Optional file where every change made by grants, revokes and reconcile is appended to a hash-chained JSONL audit log. ($BATON_AUDIT_LOG)",
+	)
+	cmd.PersistentFlags().StringSlice(
+		"protected-user",
+		nil,
+		"Team user, by email or ID, whose roles grants and revokes must never change. Can be repeated. ($BATON_PROTECTED_USER)",
+	)
+	cmd.PersistentFlags().StringSlice(
+		"protected-broker-user",
+		nil,
+		"Broker user grants and revokes must never change, as USERNAME or INSTANCE_ID=USERNAME. Default users of the brokers are always protected. Can be repeated. ($BATON_PROTECTED_BROKER_USER)",
+	)
 }
@@ -15,6 +15,9 @@ import (
 
 var version = "dev"
 
+// connectorServiceCommand is the hidden command the SDK appends to the arguments of the process serving the connector.
+const connectorServiceCommand = "_connector-service"
+
 func main() {
 	ctx := context.Background()
 
@@ -27,6 +30,8 @@ func main() {
 
 	cmd.Version = version
 	cmdFlags(cmd)
+	withValidateOnly(ctx, cmd)
+	cmd.AddCommand(reconcileCmd(ctx), auditCmd(), validateCmd(ctx))
 
 	err = cmd.Execute()
 	if err != nil {
@@ -38,7 +43,16 @@ func main() {
 func getConnector(ctx context.Context, cfg *config) (types.ConnectorServer, error) {
 	l := ctxzap.Extract(ctx)
 
-	cloudamqpConnector, err := connector.New(ctx, cfg.AccessToken)
+	opts, err := connectorOptions(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	// The SDK runs the connector in a child process it starts with the same arguments, only that process serves it.
+	if cfg.BrokerEvents && isConnectorService() {
+		opts = append(opts, connector.WithBrokerEvents(cfg.BrokerEventsLog))
+	}
+
+	cloudamqpConnector, err := connector.New(ctx, cfg.AccessToken, opts...)
 	if err != nil {
 		l.Error("error creating connector", zap.Error(err))
 		return nil, err
@@ -52,3 +66,66 @@ func getConnector(ctx context.Context, cfg *config) (types.ConnectorServer, erro
 
 	return connector, nil
 }
+
+// isConnectorService tells whether the process is the child process the SDK starts to serve the connector.
+func isConnectorService() bool {
+	return len(os.Args) > 1 && os.Args[len(os.Args)-1] == connectorServiceCommand
+}
+
+// connectorOptions returns the options of the connector set by the configuration.
+func connectorOptions(ctx context.Context, cfg *config) ([]connector.Option, error) {
+	l := ctxzap.Extract(ctx)
+
+	accounts, err := parseAccounts(cfg.Accounts)
+	if err != nil {
+		l.Error("error parsing accounts", zap.Error(err))
+		return nil, err
+	}
+
+	definitionsFiles, err := parseDefinitionsFiles(cfg.DefinitionsFiles)
+	if err != nil {
+		l.Error("error parsing definitions files", zap.Error(err))
+		return nil, err
+	}
+
+	protectedBrokerUsers, err := parseProtectedBrokerUsers(cfg.ProtectedBrokerUser)
+	if err != nil {
+		l.Error("error parsing protected broker users", zap.Error(err))
+		return nil, err
+	}
+
+	defaultLimits, err := parseDefaultLimits(cfg.DefaultUserLimits, cfg.DefaultVhostLimits)
+	if err != nil {
+		l.Error("error parsing default limits", zap.Error(err))
+		return nil, err
+	}
+
+	instanceTimeout, err := parseInstanceTimeout(cfg.InstanceTimeout)
+	if err != nil {
+		l.Error("error parsing instance timeout", zap.Error(err))
+		return nil, err
+	}
+
+	instanceFilter, err := parseInstanceFilter(cfg.IncludeInstances, cfg.ExcludeInstances)
+	if err != nil {
+		l.Error("error parsing instance filters", zap.Error(err))
+		return nil, err
+	}
+
+	opts := []connector.Option{
+		connector.WithDefinitionsFiles(definitionsFiles),
+		connector.WithConnectionStateFile(cfg.ConnectionStateFile),
+		connector.WithDryRun(cfg.DryRun),
+		connector.WithTeamWriteAccess(cfg.TeamWriteAccess),
+		connector.WithProtectedUsers(cfg.ProtectedUsers, protectedBrokerUsers),
+		connector.WithAuditLog(cfg.AuditLog),
+		connector.WithDefaultLimits(defaultLimits),
+		connector.WithInstanceConcurrency(cfg.InstanceConcurrency, instanceTimeout),
+		connector.WithInstanceFilter(instanceFilter),
+	}
+	for _, account := range accounts {
+		opts = append(opts, connector.WithAccount(account.name, account.token))
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
+	"github.com/conductorone/baton-cloudamqp/pkg/connector"
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// reconcileCmd diffs the team account against a desired state file and optionally applies the difference.
+func reconcileCmd(ctx context.Context) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "reconcile STATE_FILE",
+		Short: "Reconcile team membership with a YAML or JSON file of emails and roles",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.New()
+			v.SetEnvPrefix("baton")
+			v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+			v.AutomaticEnv()
+			if err := v.BindPFlags(cmd.Flags()); err != nil {
+				return err
+			}
+
+			token, err := reconcileToken(v)
+			if err != nil {
+				return err
+			}
+
+			output := v.GetString("output")
+			if output != "text" && output != "json" {
+				return fmt.Errorf("unknown output format %q", output)
+			}
+
+			state, err := loadTeamState(args[0])
+			if err != nil {
+				return err
+			}
+
+			httpClient, err := uhttp.NewClient(ctx)
+			if err != nil {
+				return err
+			}
+			client := cloudamqp.NewClient(httpClient, token)
+
+			plan, err := connector.PlanTeam(ctx, client, state, v.GetStringSlice("protected-user"))
+			if err != nil {
+				return err
+			}
+
+			err = printTeamPlan(cmd.OutOrStdout(), plan, output)
+			if err != nil {
+				return err
+			}
+
+			if !v.GetBool("apply") || len(plan.Changes) == 0 {
+				return nil
+			}
+
+			if v.GetBool("dry-run") {
+				fmt.Fprintln(cmd.ErrOrStderr(), "Dry run, not applied.")
+				return nil
+			}
+
+			if !v.GetBool("yes") && !confirm(cmd.InOrStdin(), cmd.ErrOrStderr(), len(plan.Changes)) {
+				fmt.Fprintln(cmd.ErrOrStderr(), "Not applied.")
+				return nil
+			}
+
+			var auditLog *connector.AuditLog
+			if path := v.GetString("audit-log"); path != "" {
+				auditLog = connector.NewAuditLog(path, false)
+			}
+
+			err = connector.ApplyTeamPlan(ctx, client, plan, auditLog)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(cmd.ErrOrStderr(), "Applied %d changes.\n", len(plan.Changes))
+
+			return nil
+		},
+	}
+
+	cmd.Flags().Bool("apply", false, "Make the planned changes to the team account")
+	cmd.Flags().Bool("yes", false, "Apply the plan without asking for confirmation")
+	cmd.Flags().String("output", "text", "The output format of the plan: text, json")
+	cmd.Flags().String("account-name", "", "The named account to reconcile, when accounts are provided with --account")
+
+	return cmd
+}
+
+// reconcileToken returns the access token of the team account to reconcile, either the one provided with --token or
+// the one of the account selected with --account-name.
+func reconcileToken(v *viper.Viper) (string, error) {
+	accounts, err := parseAccounts(v.GetStringSlice("account"))
+	if err != nil {
+		return "", err
+	}
+
+	name := v.GetString("account-name")
+	if name == "" {
+		token := v.GetString("token")
+		if token == "" {
+			return "", fmt.Errorf("access token is missing")
+		}
+
+		return token, nil
+	}
+
+	for _, account := range accounts {
+		if account.name == name {
+			return account.token, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown account %s", name)
+}
+
+// loadTeamState reads a desired state file, JSON being valid YAML both formats are read the same way.
+func loadTeamState(path string) (*connector.TeamState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read team state: %w", err)
+	}
+
+	state := &connector.TeamState{}
+	err = yaml.Unmarshal(data, state)
+	if err != nil {
+		return nil, fmt.Errorf("invalid team state %s: %w", path, err)
+	}
+
+	return state, nil
+}
+
+func printTeamPlan(w io.Writer, plan *connector.TeamPlan, output string) error {
+	if output == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(plan)
+	}
+
+	for _, change := range plan.Protected {
+		fmt.Fprintf(w, "- skipped, user is protected: %s\n", change.String())
+	}
+
+	if len(plan.Changes) == 0 {
+		fmt.Fprintln(w, "Team account matches the desired state.")
+		return nil
+	}
+
+	for _, change := range plan.Changes {
+		fmt.Fprintf(w, "- %s\n", change.String())
+	}
+
+	return nil
+}
+
+func confirm(in io.Reader, out io.Writer, changes int) bool {
+	fmt.Fprintf(out, "Apply %d changes to the team account? [y/N] ", changes)
+
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && answer == "" {
+		return false
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == "yes"
+}
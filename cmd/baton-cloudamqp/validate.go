@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/conductorone/baton-cloudamqp/pkg/connector"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// validateCmd validates the access tokens and prints which capabilities they have, without syncing.
+func validateCmd(ctx context.Context) *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate",
+		Short: "Validate the access tokens and print which capabilities they have",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(cmd)
+			if err != nil {
+				return err
+			}
+
+			return validateOnly(ctx, cmd.OutOrStdout(), cfg)
+		},
+	}
+}
+
+// withValidateOnly makes the connector command print the capability report and exit instead of syncing when
+// --validate-only is set.
+func withValidateOnly(ctx context.Context, cmd *cobra.Command) {
+	cmd.Flags().Bool(
+		"validate-only",
+		false,
+		"Validate the access tokens and print which capabilities they have, without syncing. ($BATON_VALIDATE_ONLY)",
+	)
+
+	run := cmd.RunE
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+
+		if !cfg.ValidateOnly {
+			return run(cmd, args)
+		}
+
+		return validateOnly(ctx, cmd.OutOrStdout(), cfg)
+	}
+}
+
+// loadConfig reads the configuration from the flags and environment of provided command.
+func loadConfig(cmd *cobra.Command) (*config, error) {
+	v := viper.New()
+	v.SetEnvPrefix("baton")
+	v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	v.AutomaticEnv()
+	if err := v.BindPFlags(cmd.Flags()); err != nil {
+		return nil, err
+	}
+
+	cfg := &config{}
+	if err := v.Unmarshal(cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// validateOnly creates the connector from provided configuration and prints which capabilities its access tokens
+// have.
+func validateOnly(ctx context.Context, w io.Writer, cfg *config) error {
+	// Broker events are only followed by a running connector.
+	cfg.BrokerEvents, cfg.BrokerEventsLog = false, ""
+	if err := validateConfig(ctx, cfg); err != nil {
+		return err
+	}
+
+	opts, err := connectorOptions(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	c, err := connector.New(ctx, cfg.AccessToken, opts...)
+	if err != nil {
+		return err
+	}
+
+	return printCapabilities(ctx, w, c)
+}
+
+// printCapabilities validates the connector and prints which capabilities the configured access tokens have.
+func printCapabilities(ctx context.Context, w io.Writer, c *connector.CloudAMQP) error {
+	report, err := c.ProbeCapabilities(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = report.Write(w)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case report.Lacks(connector.CapabilityWriteTeam):
+		fmt.Fprintln(w, "Team provisioning is not available with the configured access tokens.")
+	case !report.Can(connector.CapabilityWriteTeam):
+		fmt.Fprintln(w, "Team provisioning was not checked, pass --team-write-access if the access tokens are allowed to change the team.")
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package cloudamqp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Message brokers CloudAMQP instances can run.
+const (
+	BrokerEngineRabbitMQ = "rabbitmq"
+	BrokerEngineLavinMQ  = "lavinmq"
+)
+
+// ErrUnsupportedByBroker is returned for features the broker running on an instance does not offer.
+var ErrUnsupportedByBroker = errors.New("not supported by the broker")
+
+// Broker is the management API of the message broker running on a CloudAMQP instance.
+type Broker interface {
+	// Engine returns the message broker behind the API.
+	Engine() string
+	// GetDefinitions returns users, vhosts, permissions, policies, operator policies, queues, exchanges and parameters
+	// of the broker.
+	GetDefinitions(ctx context.Context) (*Definitions, error)
+	// GetConnections returns all client connections currently open to the broker.
+	GetConnections(ctx context.Context) ([]Connection, error)
+	// GetUserLimits returns limits of all broker users having some.
+	GetUserLimits(ctx context.Context) ([]UserLimits, error)
+	// SetUserLimit sets a limit of provided broker user.
+	SetUserLimit(ctx context.Context, username string, name string, value int64) error
+	// ClearUserLimit removes a limit of provided broker user.
+	ClearUserLimit(ctx context.Context, username string, name string) error
+	// GetVhostLimits returns limits of all virtual hosts having some.
+	GetVhostLimits(ctx context.Context) ([]VhostLimits, error)
+	// SetVhostLimit sets a limit of provided virtual host.
+	SetVhostLimit(ctx context.Context, vhost string, name string, value int64) error
+	// ClearVhostLimit removes a limit of provided virtual host.
+	ClearVhostLimit(ctx context.Context, vhost string, name string) error
+}
+
+var (
+	_ Broker = (*ManagementClient)(nil)
+	_ Broker = (*LavinMQClient)(nil)
+)
+
+// isUnsupportedEndpoint tells whether a request failed because the broker does not implement the endpoint.
+func isUnsupportedEndpoint(err error) bool {
+	switch status.Code(err) {
+	case codes.Code(http.StatusNotFound), codes.Code(http.StatusMethodNotAllowed), codes.Code(http.StatusNotImplemented):
+		return true
+	default:
+		return false
+	}
+}
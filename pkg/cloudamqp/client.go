@@ -1,36 +1,89 @@
 package cloudamqp
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
 const BaseURL = "https://customer.cloudamqp.com/api"
+const AccountURL = BaseURL + "/account"
 const UsersBaseURL = BaseURL + "/team"
 const UserBaseURL = BaseURL + "/team/%s"
+const InviteUserURL = BaseURL + "/team/invite"
+const InstancesBaseURL = BaseURL + "/instances"
+const InstanceBaseURL = BaseURL + "/instances/%d"
+const VpcsBaseURL = BaseURL + "/vpcs"
+const VpcPeeringsBaseURL = BaseURL + "/vpcs/%d/vpc-peering"
 
 type Client struct {
 	httpClient *http.Client
 	Password   string
+	username   string
+	dryRun     bool
+
+	instancesMtx sync.Mutex
+	instances    map[int]*Instance
+
+	responsesMtx  sync.Mutex
+	responses     map[string]*cachedResponse
+	responsesUses uint64
 }
 
 type UsersResponse = []User
+type InstancesResponse = []Instance
+type VpcsResponse = []Vpc
+type VpcPeeringsResponse = []VpcPeering
 
 func NewClient(httpClient *http.Client, password string) *Client {
 	return &Client{
 		httpClient: httpClient,
 		Password:   password,
+		instances:  make(map[int]*Instance),
+		responses:  make(map[string]*cachedResponse),
 	}
 }
 
+// EnableDryRun makes the client log requests changing anything instead of sending them. Clients of instances and
+// brokers returned by the client inherit it.
+func (c *Client) EnableDryRun() {
+	c.dryRun = true
+}
+
+// GetAccount returns the team account of the API key, or nil when the API does not tell. The endpoint is optional:
+// answers telling it is not supported leave the account unknown rather than failing.
+func (c *Client) GetAccount(ctx context.Context) (*Account, error) {
+	var account Account
+
+	err := c.get(
+		ctx,
+		AccountURL,
+		&account,
+	)
+
+	if err != nil {
+		if isUnsupportedEndpoint(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &account, nil
+}
+
 // GetUsers returns all users under the team account.
 func (c *Client) GetUsers(ctx context.Context) ([]User, error) {
 	var usersResponse UsersResponse
@@ -72,6 +125,210 @@ func (c *Client) UpdateUserRole(ctx context.Context, userId string, role string)
 	return nil
 }
 
+func NewInviteUserPayload(email string, role string) url.Values {
+	payload := url.Values{}
+
+	payload.Set("email", email)
+	payload.Set("role", role)
+
+	return payload
+}
+
+// InviteUser invites provided email to the team account with provided role.
+func (c *Client) InviteUser(ctx context.Context, email string, role string) error {
+	err := c.post(
+		ctx,
+		InviteUserURL,
+		NewInviteUserPayload(email, role),
+		nil,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RemoveUser removes provided user from the team account.
+func (c *Client) RemoveUser(ctx context.Context, userId string) error {
+	err := c.delete(
+		ctx,
+		fmt.Sprintf(UserBaseURL, userId),
+		nil,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetInstances returns all instances under the team account.
+func (c *Client) GetInstances(ctx context.Context) ([]Instance, error) {
+	var instancesResponse InstancesResponse
+
+	err := c.get(
+		ctx,
+		InstancesBaseURL,
+		&instancesResponse,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return instancesResponse, nil
+}
+
+// GetInstance returns details of provided instance, including the credentials used to access its APIs.
+func (c *Client) GetInstance(ctx context.Context, instanceId int) (*Instance, error) {
+	var instanceResponse Instance
+
+	err := c.get(
+		ctx,
+		fmt.Sprintf(InstanceBaseURL, instanceId),
+		&instanceResponse,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &instanceResponse, nil
+}
+
+// Instance returns cached details of provided instance, fetching them on first use.
+func (c *Client) Instance(ctx context.Context, instanceId int) (*Instance, error) {
+	return c.instance(ctx, instanceId)
+}
+
+// instance returns cached details of provided instance, fetching them on first use.
+func (c *Client) instance(ctx context.Context, instanceId int) (*Instance, error) {
+	c.instancesMtx.Lock()
+	instance, ok := c.instances[instanceId]
+	c.instancesMtx.Unlock()
+
+	if ok {
+		return instance, nil
+	}
+
+	// The lock is not held while requesting, so that instances are fetched concurrently. An instance fetched twice at
+	// once is stored twice with the same data.
+	instance, err := c.GetInstance(ctx, instanceId)
+	if err != nil {
+		return nil, err
+	}
+
+	c.instancesMtx.Lock()
+	c.instances[instanceId] = instance
+	c.instancesMtx.Unlock()
+
+	return instance, nil
+}
+
+// InstanceHostname returns the hostname of the broker running on provided instance.
+func (c *Client) InstanceHostname(ctx context.Context, instanceId int) (string, error) {
+	instance, err := c.instance(ctx, instanceId)
+	if err != nil {
+		return "", err
+	}
+
+	return instance.Hostname(), nil
+}
+
+// InstanceDefaultUser returns the broker user provided instance was created with.
+func (c *Client) InstanceDefaultUser(ctx context.Context, instanceId int) (string, error) {
+	instance, err := c.instance(ctx, instanceId)
+	if err != nil {
+		return "", err
+	}
+
+	return instance.DefaultUser(), nil
+}
+
+// InstanceClient returns a client for the instance API of provided instance.
+func (c *Client) InstanceClient(ctx context.Context, instanceId int) (*InstanceClient, error) {
+	instance, err := c.instance(ctx, instanceId)
+	if err != nil {
+		return nil, err
+	}
+
+	instanceClient := NewInstanceClient(c.httpClient, instance.ApiKey)
+	instanceClient.api.dryRun = c.dryRun
+
+	return instanceClient, nil
+}
+
+// Broker returns a client for the management API of the broker running on provided instance, picked after its engine.
+func (c *Client) Broker(ctx context.Context, instanceId int) (Broker, error) {
+	instance, err := c.instance(ctx, instanceId)
+	if err != nil {
+		return nil, err
+	}
+
+	managementClient, err := NewManagementClientFromURL(c.httpClient, instance.Url)
+	if err != nil {
+		return nil, err
+	}
+	managementClient.api.dryRun = c.dryRun
+
+	if instance.Engine() == BrokerEngineLavinMQ {
+		return NewLavinMQClient(managementClient), nil
+	}
+
+	return managementClient, nil
+}
+
+// EventSubscriber returns a subscriber to access events of the broker running on provided instance.
+func (c *Client) EventSubscriber(ctx context.Context, instanceId int, opts ...EventSubscriberOption) (*EventSubscriber, error) {
+	instance, err := c.instance(ctx, instanceId)
+	if err != nil {
+		return nil, err
+	}
+
+	if instance.Engine() != BrokerEngineRabbitMQ {
+		return nil, fmt.Errorf("access events are %w", ErrUnsupportedByBroker)
+	}
+
+	return NewEventSubscriber(instance.Url, opts...), nil
+}
+
+// GetVpcs returns all dedicated VPCs under the team account.
+func (c *Client) GetVpcs(ctx context.Context) ([]Vpc, error) {
+	var vpcsResponse VpcsResponse
+
+	err := c.get(
+		ctx,
+		VpcsBaseURL,
+		&vpcsResponse,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return vpcsResponse, nil
+}
+
+// GetVpcPeerings returns all peering connections of provided VPC.
+func (c *Client) GetVpcPeerings(ctx context.Context, vpcId int) ([]VpcPeering, error) {
+	var peeringsResponse VpcPeeringsResponse
+
+	err := c.get(
+		ctx,
+		fmt.Sprintf(VpcPeeringsBaseURL, vpcId),
+		&peeringsResponse,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return peeringsResponse, nil
+}
+
 func (c *Client) get(ctx context.Context, urlAddress string, resourceResponse interface{}) error {
 	return c.doRequest(ctx, urlAddress, http.MethodGet, nil, resourceResponse)
 }
@@ -80,6 +337,18 @@ func (c *Client) put(ctx context.Context, urlAddress string, data url.Values, re
 	return c.doRequest(ctx, urlAddress, http.MethodPut, data, resourceResponse)
 }
 
+func (c *Client) post(ctx context.Context, urlAddress string, data url.Values, resourceResponse interface{}) error {
+	return c.doRequest(ctx, urlAddress, http.MethodPost, data, resourceResponse)
+}
+
+func (c *Client) delete(ctx context.Context, urlAddress string, resourceResponse interface{}) error {
+	return c.doRequest(ctx, urlAddress, http.MethodDelete, nil, resourceResponse)
+}
+
+func (c *Client) putJSON(ctx context.Context, urlAddress string, data interface{}, resourceResponse interface{}) error {
+	return c.doJSONRequest(ctx, urlAddress, http.MethodPut, data, resourceResponse)
+}
+
 func (c *Client) doRequest(
 	ctx context.Context,
 	urlAddress string,
@@ -95,13 +364,47 @@ func (c *Client) doRequest(
 		body = *bodyReader
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, urlAddress, &body)
+	return c.send(ctx, urlAddress, method, "application/x-www-form-urlencoded", &body, resourceResponse)
+}
+
+func (c *Client) doJSONRequest(
+	ctx context.Context,
+	urlAddress string,
+	method string,
+	data interface{},
+	resourceResponse interface{},
+) error {
+	encodedData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return c.send(ctx, urlAddress, method, "application/json", bytes.NewReader(encodedData), resourceResponse)
+}
+
+func (c *Client) send(
+	ctx context.Context,
+	urlAddress string,
+	method string,
+	contentType string,
+	body io.Reader,
+	resourceResponse interface{},
+) error {
+	if c.dryRun && method != http.MethodGet {
+		return dryRunRequest(ctx, urlAddress, method, body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, urlAddress, body)
 	if err != nil {
 		return err
 	}
 
-	req.Header.Set("content-type", "application/x-www-form-urlencoded")
-	req.Header.Set("Authorization", constructAuth(c.Password))
+	req.Header.Set("content-type", contentType)
+	req.Header.Set("Authorization", constructAuth(c.username, c.Password))
+
+	if method == http.MethodGet {
+		c.setConditionalHeaders(req, urlAddress)
+	}
 
 	rawResponse, err := c.httpClient.Do(req)
 	if err != nil {
@@ -110,19 +413,57 @@ func (c *Client) doRequest(
 
 	defer rawResponse.Body.Close()
 
-	if rawResponse.StatusCode >= 300 {
+	var data []byte
+	switch {
+	case method == http.MethodGet && rawResponse.StatusCode == http.StatusNotModified:
+		cachedData, ok := c.notModifiedBody(urlAddress)
+		if !ok {
+			return status.Error(codes.Code(rawResponse.StatusCode), "Request failed")
+		}
+		data = cachedData
+	case rawResponse.StatusCode >= 300:
 		return status.Error(codes.Code(rawResponse.StatusCode), "Request failed")
+	default:
+		data, err = io.ReadAll(rawResponse.Body)
+		if err != nil {
+			return err
+		}
+
+		if method == http.MethodGet {
+			c.storeResponse(urlAddress, rawResponse.Header, data)
+		}
 	}
 
-	if err := json.NewDecoder(rawResponse.Body).Decode(&resourceResponse); err != nil {
+	if resourceResponse == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(data, &resourceResponse); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func constructAuth(pass string) string {
-	credentials := fmt.Sprintf("%s:%s", "", pass)
+// dryRunRequest logs a request that would have been sent, leaving out its response.
+func dryRunRequest(ctx context.Context, urlAddress string, method string, body io.Reader) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	ctxzap.Extract(ctx).Info(
+		"cloudamqp-connector: dry run, request not sent",
+		zap.String("method", method),
+		zap.String("url", urlAddress),
+		zap.ByteString("body", data),
+	)
+
+	return nil
+}
+
+func constructAuth(user string, pass string) string {
+	credentials := fmt.Sprintf("%s:%s", user, pass)
 	encodedCredentials := base64.StdEncoding.EncodeToString([]byte(credentials))
 
 	return "Basic " + encodedCredentials
@@ -0,0 +1,130 @@
+package cloudamqp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+)
+
+// maxCachedResponses bounds the number of responses kept by a client, the least recently used ones being evicted.
+const maxCachedResponses = 256
+
+// cachedResponse is the last successful response to a GET request, kept to send the request conditionally next time
+// and to reuse the body when the API tells it has not changed. Responses are kept in memory for the life of the client
+// only, so the first sync of a process fetches them in full.
+type cachedResponse struct {
+	etag         string
+	lastModified string
+	body         []byte
+	version      string
+	lastUsed     uint64
+}
+
+// useResponse returns the response cached for a URL, marking it as recently used. The caller must hold responsesMtx.
+func (c *Client) useResponse(urlAddress string) (*cachedResponse, bool) {
+	cached, ok := c.responses[urlAddress]
+	if !ok {
+		return nil, false
+	}
+
+	c.responsesUses++
+	cached.lastUsed = c.responsesUses
+
+	return cached, true
+}
+
+// evictResponses drops the least recently used responses until there is room for another one. The caller must hold
+// responsesMtx.
+func (c *Client) evictResponses() {
+	for len(c.responses) >= maxCachedResponses {
+		var oldest string
+		var oldestUse uint64
+		first := true
+		for urlAddress, cached := range c.responses {
+			if first || cached.lastUsed < oldestUse {
+				oldest, oldestUse, first = urlAddress, cached.lastUsed, false
+			}
+		}
+
+		delete(c.responses, oldest)
+	}
+}
+
+// setConditionalHeaders makes a GET request conditional on the response cached for its URL, if any.
+func (c *Client) setConditionalHeaders(req *http.Request, urlAddress string) {
+	c.responsesMtx.Lock()
+	cached, ok := c.useResponse(urlAddress)
+	c.responsesMtx.Unlock()
+
+	if !ok {
+		return
+	}
+
+	if cached.etag != "" {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+
+	if cached.lastModified != "" {
+		req.Header.Set("If-Modified-Since", cached.lastModified)
+	}
+}
+
+// notModifiedBody returns the cached body of a URL the API answered with 304 Not Modified.
+func (c *Client) notModifiedBody(urlAddress string) ([]byte, bool) {
+	c.responsesMtx.Lock()
+	defer c.responsesMtx.Unlock()
+
+	cached, ok := c.useResponse(urlAddress)
+	if !ok {
+		return nil, false
+	}
+
+	return cached.body, true
+}
+
+// storeResponse keeps the response to a GET request. Its version is the ETag the API sent, or a hash of the body
+// when the API sent none.
+func (c *Client) storeResponse(urlAddress string, header http.Header, body []byte) {
+	cached := &cachedResponse{
+		etag:         header.Get("ETag"),
+		lastModified: header.Get("Last-Modified"),
+		body:         body,
+	}
+
+	if cached.etag != "" {
+		cached.version = cached.etag
+	} else {
+		hash := sha256.Sum256(body)
+		cached.version = "sha256:" + hex.EncodeToString(hash[:])
+	}
+
+	c.responsesMtx.Lock()
+	defer c.responsesMtx.Unlock()
+
+	if _, ok := c.responses[urlAddress]; !ok {
+		c.evictResponses()
+	}
+
+	c.responsesUses++
+	cached.lastUsed = c.responsesUses
+	c.responses[urlAddress] = cached
+}
+
+// ResponseVersion returns the version of the last response to a GET request of provided URL, which changes whenever
+// the response does, or an empty string when the URL was not requested yet.
+func (c *Client) ResponseVersion(urlAddress string) string {
+	c.responsesMtx.Lock()
+	defer c.responsesMtx.Unlock()
+
+	cached, ok := c.responses[urlAddress]
+	if !ok {
+		return ""
+	}
+
+	return cached.version
+}
+
+// UsersVersion returns the version of the team users last listed.
+func (c *Client) UsersVersion() string {
+	return c.ResponseVersion(UsersBaseURL)
+}
@@ -0,0 +1,33 @@
+package cloudamqp
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestResponsesEviction(t *testing.T) {
+	c := NewClient(http.DefaultClient, "")
+
+	c.storeResponse("kept", http.Header{"Etag": []string{`"kept"`}}, nil)
+	for i := 0; i < maxCachedResponses; i++ {
+		c.storeResponse(fmt.Sprintf("url-%d", i), http.Header{}, []byte{byte(i)})
+
+		// Using a response keeps it from being evicted.
+		if _, ok := c.notModifiedBody("kept"); !ok {
+			t.Fatalf("recently used response evicted after %d responses", i+1)
+		}
+	}
+
+	if len(c.responses) != maxCachedResponses {
+		t.Errorf("%d responses cached, want %d", len(c.responses), maxCachedResponses)
+	}
+
+	if version := c.ResponseVersion("url-0"); version != "" {
+		t.Errorf("least recently used response not evicted, version %q", version)
+	}
+
+	if version := c.ResponseVersion("kept"); version != `"kept"` {
+		t.Errorf("version = %q, want the ETag", version)
+	}
+}
@@ -0,0 +1,242 @@
+package cloudamqp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Exchanges every virtual host has, which definitions exports leave out.
+var builtinExchanges = []Exchange{
+	{Name: "", Type: "direct", Durable: true},
+	{Name: "amq.direct", Type: "direct", Durable: true},
+	{Name: "amq.fanout", Type: "fanout", Durable: true},
+	{Name: "amq.headers", Type: "headers", Durable: true},
+	{Name: "amq.match", Type: "headers", Durable: true},
+	{Name: "amq.topic", Type: "topic", Durable: true},
+	{Name: "amq.rabbitmq.trace", Type: "topic", Durable: true},
+}
+
+const (
+	ParameterComponentShovel             = "shovel"
+	ParameterComponentFederationUpstream = "federation-upstream"
+)
+
+// URIList holds one or more AMQP URIs, which definitions provide either as a string or as a list.
+type URIList []string
+
+func (u *URIList) UnmarshalJSON(data []byte) error {
+	var uris []string
+	if err := json.Unmarshal(data, &uris); err == nil {
+		*u = uris
+		return nil
+	}
+
+	var uri string
+	if err := json.Unmarshal(data, &uri); err != nil {
+		return err
+	}
+
+	*u = URIList{uri}
+
+	return nil
+}
+
+// Parameter is a runtime parameter of a virtual host, such as a dynamic shovel or a federation upstream.
+type Parameter struct {
+	Vhost     string          `json:"vhost"`
+	Component string          `json:"component"`
+	Name      string          `json:"name"`
+	Value     json.RawMessage `json:"value"`
+}
+
+type Shovel struct {
+	Name         string  `json:"-"`
+	Vhost        string  `json:"-"`
+	SrcURIs      URIList `json:"src-uri"`
+	SrcQueue     string  `json:"src-queue"`
+	SrcExchange  string  `json:"src-exchange"`
+	DestURIs     URIList `json:"dest-uri"`
+	DestQueue    string  `json:"dest-queue"`
+	DestExchange string  `json:"dest-exchange"`
+}
+
+type FederationUpstream struct {
+	Name     string  `json:"-"`
+	Vhost    string  `json:"-"`
+	URIs     URIList `json:"uri"`
+	Exchange string  `json:"exchange"`
+	Queue    string  `json:"queue"`
+}
+
+type TopicPermission struct {
+	User     string `json:"user"`
+	Vhost    string `json:"vhost"`
+	Exchange string `json:"exchange"`
+	Write    string `json:"write"`
+	Read     string `json:"read"`
+}
+
+type Policy struct {
+	Vhost      string                 `json:"vhost"`
+	Name       string                 `json:"name"`
+	Pattern    string                 `json:"pattern"`
+	ApplyTo    string                 `json:"apply-to"`
+	Definition map[string]interface{} `json:"definition"`
+	Priority   int                    `json:"priority"`
+}
+
+// Definitions is the content of a RabbitMQ or LavinMQ definitions export.
+type Definitions struct {
+	RabbitVersion    string            `json:"rabbit_version"`
+	Users            []BrokerUser      `json:"users"`
+	Vhosts           []Vhost           `json:"vhosts"`
+	Permissions      []Permission      `json:"permissions"`
+	TopicPermissions []TopicPermission `json:"topic_permissions"`
+	Policies         []Policy          `json:"policies"`
+	OperatorPolicies []Policy          `json:"operator_policies,omitempty"`
+	Queues           []Queue           `json:"queues"`
+	Exchanges        []Exchange        `json:"exchanges"`
+	Parameters       []Parameter       `json:"parameters"`
+}
+
+// ParseDefinitions parses a definitions export, as read from a file or returned by the management API.
+func ParseDefinitions(data []byte) (*Definitions, error) {
+	var definitions Definitions
+
+	if err := json.Unmarshal(data, &definitions); err != nil {
+		return nil, fmt.Errorf("invalid definitions: %w", err)
+	}
+
+	return &definitions, nil
+}
+
+// LoadDefinitionsFile parses the definitions export stored in provided file.
+func LoadDefinitionsFile(path string) (*Definitions, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	definitions, err := ParseDefinitions(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return definitions, nil
+}
+
+// VhostPermissions returns permissions of all users in provided virtual host.
+func (d *Definitions) VhostPermissions(vhost string) []Permission {
+	var rv []Permission
+	for _, permission := range d.Permissions {
+		if permission.Vhost == vhost {
+			rv = append(rv, permission)
+		}
+	}
+
+	return rv
+}
+
+// ExchangeTopicPermissions returns the topic permissions set on provided exchange of a virtual host.
+func (d *Definitions) ExchangeTopicPermissions(vhost string, exchange string) []TopicPermission {
+	var rv []TopicPermission
+	for _, permission := range d.TopicPermissions {
+		if permission.Vhost == vhost && permission.Exchange == exchange {
+			rv = append(rv, permission)
+		}
+	}
+
+	return rv
+}
+
+// VhostPolicies returns all policies in provided virtual host.
+func (d *Definitions) VhostPolicies(vhost string) []Policy {
+	return vhostPolicies(d.Policies, vhost)
+}
+
+// VhostOperatorPolicies returns all operator policies in provided virtual host.
+func (d *Definitions) VhostOperatorPolicies(vhost string) []Policy {
+	return vhostPolicies(d.OperatorPolicies, vhost)
+}
+
+func vhostPolicies(policies []Policy, vhost string) []Policy {
+	var rv []Policy
+	for _, policy := range policies {
+		if policy.Vhost == vhost {
+			rv = append(rv, policy)
+		}
+	}
+
+	return rv
+}
+
+// VhostQueues returns all queues in provided virtual host.
+func (d *Definitions) VhostQueues(vhost string) []Queue {
+	var rv []Queue
+	for _, queue := range d.Queues {
+		if queue.Vhost == vhost {
+			rv = append(rv, queue)
+		}
+	}
+
+	return rv
+}
+
+// VhostExchanges returns all exchanges in provided virtual host, including the built-in ones.
+func (d *Definitions) VhostExchanges(vhost string) []Exchange {
+	rv := make([]Exchange, 0, len(builtinExchanges))
+	for _, exchange := range builtinExchanges {
+		exchange.Vhost = vhost
+		rv = append(rv, exchange)
+	}
+
+	for _, exchange := range d.Exchanges {
+		if exchange.Vhost == vhost {
+			rv = append(rv, exchange)
+		}
+	}
+
+	return rv
+}
+
+// VhostShovels returns all dynamic shovels in provided virtual host.
+func (d *Definitions) VhostShovels(vhost string) ([]Shovel, error) {
+	var rv []Shovel
+	for _, parameter := range d.vhostParameters(vhost, ParameterComponentShovel) {
+		shovel := Shovel{Name: parameter.Name, Vhost: parameter.Vhost}
+		if err := json.Unmarshal(parameter.Value, &shovel); err != nil {
+			return nil, fmt.Errorf("invalid shovel %s: %w", parameter.Name, err)
+		}
+
+		rv = append(rv, shovel)
+	}
+
+	return rv, nil
+}
+
+// VhostFederationUpstreams returns all federation upstreams in provided virtual host.
+func (d *Definitions) VhostFederationUpstreams(vhost string) ([]FederationUpstream, error) {
+	var rv []FederationUpstream
+	for _, parameter := range d.vhostParameters(vhost, ParameterComponentFederationUpstream) {
+		upstream := FederationUpstream{Name: parameter.Name, Vhost: parameter.Vhost}
+		if err := json.Unmarshal(parameter.Value, &upstream); err != nil {
+			return nil, fmt.Errorf("invalid federation upstream %s: %w", parameter.Name, err)
+		}
+
+		rv = append(rv, upstream)
+	}
+
+	return rv, nil
+}
+
+func (d *Definitions) vhostParameters(vhost string, component string) []Parameter {
+	var rv []Parameter
+	for _, parameter := range d.Parameters {
+		if parameter.Vhost == vhost && parameter.Component == component {
+			rv = append(rv, parameter)
+		}
+	}
+
+	return rv
+}
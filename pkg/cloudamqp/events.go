@@ -0,0 +1,195 @@
+package cloudamqp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// EventExchange is the exchange the rabbitmq_event_exchange plugin publishes broker events to.
+const EventExchange = "amq.rabbitmq.event"
+
+// Broker events changing who has access to what on the broker.
+const (
+	EventUserCreated       = "user.created"
+	EventUserDeleted       = "user.deleted"
+	EventUserTagsSet       = "user.tags.set"
+	EventPermissionCreated = "permission.created"
+	EventPermissionDeleted = "permission.deleted"
+	EventVhostCreated      = "vhost.created"
+)
+
+var AccessEvents = []string{
+	EventUserCreated,
+	EventUserDeleted,
+	EventUserTagsSet,
+	EventPermissionCreated,
+	EventPermissionDeleted,
+	EventVhostCreated,
+}
+
+// BrokerEvent is an event published by the broker to the event exchange.
+type BrokerEvent struct {
+	Type       string            `json:"type"`
+	Timestamp  time.Time         `json:"timestamp"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+// Name returns the name of the user or the vhost the event is about.
+func (e *BrokerEvent) Name() string {
+	return e.Properties["name"]
+}
+
+// User returns the user the event is about, permission events refer to it separately from the vhost.
+func (e *BrokerEvent) User() string {
+	if user, ok := e.Properties["user"]; ok {
+		return user
+	}
+
+	return e.Properties["name"]
+}
+
+// Vhost returns the vhost the event is about.
+func (e *BrokerEvent) Vhost() string {
+	if vhost, ok := e.Properties["vhost"]; ok {
+		return vhost
+	}
+
+	return e.Properties["name"]
+}
+
+// EventSubscriber consumes access events of a broker over AMQP.
+type EventSubscriber struct {
+	amqpURL string
+	dial    func(network, addr string) (net.Conn, error)
+}
+
+type EventSubscriberOption func(*EventSubscriber)
+
+// WithEventDialer replaces how connections to the broker are opened, e.g. to consume events of an in-process broker.
+func WithEventDialer(dial func(network, addr string) (net.Conn, error)) EventSubscriberOption {
+	return func(s *EventSubscriber) {
+		s.dial = dial
+	}
+}
+
+func NewEventSubscriber(amqpURL string, opts ...EventSubscriberOption) *EventSubscriber {
+	s := &EventSubscriber{
+		amqpURL: amqpURL,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Subscribe consumes events of provided types until the context is done or the connection to the broker is lost.
+// Events are acknowledged once handled, a failing handler stops the subscription and leaves the event unacknowledged.
+func (s *EventSubscriber) Subscribe(ctx context.Context, eventTypes []string, handle func(context.Context, *BrokerEvent) error) error {
+	conn, err := amqp.DialConfig(s.amqpURL, amqp.Config{
+		Heartbeat: 10 * time.Second,
+		Locale:    "en_US",
+		Dial:      s.dial,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to connect to broker: %w", err)
+	}
+	defer conn.Close()
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open channel: %w", err)
+	}
+
+	// the queue lives only as long as the subscription, events published while disconnected are not kept
+	queue, err := ch.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		return fmt.Errorf("failed to declare event queue: %w", err)
+	}
+
+	for _, eventType := range eventTypes {
+		err = ch.QueueBind(queue.Name, eventType, EventExchange, false, nil)
+		if err != nil {
+			return fmt.Errorf("failed to bind event queue to %s: %w", eventType, err)
+		}
+	}
+
+	deliveries, err := ch.Consume(queue.Name, "", false, true, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("failed to consume event queue: %w", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case delivery, ok := <-deliveries:
+			if !ok {
+				return errors.New("event subscription closed by broker")
+			}
+
+			err = handle(ctx, parseBrokerEvent(&delivery))
+			if err != nil {
+				return err
+			}
+
+			err = delivery.Ack(false)
+			if err != nil {
+				return fmt.Errorf("failed to acknowledge event: %w", err)
+			}
+		}
+	}
+}
+
+// parseBrokerEvent reads an event from a delivery, the event exchange sends its properties as headers.
+func parseBrokerEvent(delivery *amqp.Delivery) *BrokerEvent {
+	event := &BrokerEvent{
+		Type:       delivery.RoutingKey,
+		Timestamp:  delivery.Timestamp,
+		Properties: make(map[string]string, len(delivery.Headers)),
+	}
+
+	for key, value := range delivery.Headers {
+		if key == "timestamp_in_ms" {
+			if ms, ok := value.(int64); ok {
+				event.Timestamp = time.UnixMilli(ms)
+			}
+
+			continue
+		}
+
+		event.Properties[key] = headerString(value)
+	}
+
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	return event
+}
+
+func headerString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			values = append(values, headerString(item))
+		}
+
+		return strings.Join(values, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,241 @@
+package cloudamqp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const InstanceAPIBaseURL = "https://api.cloudamqp.com/api"
+const FirewallBaseURL = InstanceAPIBaseURL + "/security/firewall"
+const AlarmsBaseURL = InstanceAPIBaseURL + "/alarms"
+const AlarmBaseURL = InstanceAPIBaseURL + "/alarms/%d"
+const AlarmRecipientsBaseURL = InstanceAPIBaseURL + "/alarms/recipients"
+const IntegrationsBaseURL = InstanceAPIBaseURL + "/integrations/%s"
+const IntegrationBaseURL = InstanceAPIBaseURL + "/integrations/%s/%d"
+const PluginsBaseURL = InstanceAPIBaseURL + "/plugins"
+const PluginBaseURL = InstanceAPIBaseURL + "/plugins/%s"
+
+const (
+	IntegrationKindLogs    = "logs"
+	IntegrationKindMetrics = "metrics"
+)
+
+// InstanceClient talks to the instance API of a single CloudAMQP instance, authenticated with the instance API key.
+type InstanceClient struct {
+	api *Client
+}
+
+type FirewallRulesResponse = []FirewallRule
+type AlarmsResponse = []Alarm
+type AlarmRecipientsResponse = []AlarmRecipient
+type IntegrationsResponse = []Integration
+type PluginsResponse = []Plugin
+
+func NewInstanceClient(httpClient *http.Client, apiKey string) *InstanceClient {
+	return &InstanceClient{
+		api: NewClient(httpClient, apiKey),
+	}
+}
+
+// GetFirewallRules returns all firewall rules of the instance.
+func (c *InstanceClient) GetFirewallRules(ctx context.Context) ([]FirewallRule, error) {
+	var rulesResponse FirewallRulesResponse
+
+	err := c.api.get(
+		ctx,
+		FirewallBaseURL,
+		&rulesResponse,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return rulesResponse, nil
+}
+
+// UpdateFirewallRules replaces all firewall rules of the instance with provided rules.
+func (c *InstanceClient) UpdateFirewallRules(ctx context.Context, rules []FirewallRule) error {
+	err := c.api.putJSON(
+		ctx,
+		FirewallBaseURL,
+		rules,
+		nil,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetAlarms returns all alarms of the instance.
+func (c *InstanceClient) GetAlarms(ctx context.Context) ([]Alarm, error) {
+	var alarmsResponse AlarmsResponse
+
+	err := c.api.get(
+		ctx,
+		AlarmsBaseURL,
+		&alarmsResponse,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return alarmsResponse, nil
+}
+
+// GetAlarm returns provided alarm of the instance.
+func (c *InstanceClient) GetAlarm(ctx context.Context, alarmId int) (*Alarm, error) {
+	var alarmResponse Alarm
+
+	err := c.api.get(
+		ctx,
+		fmt.Sprintf(AlarmBaseURL, alarmId),
+		&alarmResponse,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &alarmResponse, nil
+}
+
+// UpdateAlarm updates provided alarm of the instance, including its notification recipients.
+func (c *InstanceClient) UpdateAlarm(ctx context.Context, alarm *Alarm) error {
+	err := c.api.putJSON(
+		ctx,
+		fmt.Sprintf(AlarmBaseURL, alarm.Id),
+		alarm,
+		nil,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetAlarmRecipients returns all notification recipients of the instance.
+func (c *InstanceClient) GetAlarmRecipients(ctx context.Context) ([]AlarmRecipient, error) {
+	var recipientsResponse AlarmRecipientsResponse
+
+	err := c.api.get(
+		ctx,
+		AlarmRecipientsBaseURL,
+		&recipientsResponse,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return recipientsResponse, nil
+}
+
+// GetLogIntegrations returns all integrations shipping logs of the instance to third-party services.
+func (c *InstanceClient) GetLogIntegrations(ctx context.Context) ([]Integration, error) {
+	return c.getIntegrations(ctx, IntegrationKindLogs)
+}
+
+// GetMetricIntegrations returns all integrations shipping metrics of the instance to third-party services.
+func (c *InstanceClient) GetMetricIntegrations(ctx context.Context) ([]Integration, error) {
+	return c.getIntegrations(ctx, IntegrationKindMetrics)
+}
+
+// DeleteIntegration removes provided log or metric integration of the instance.
+func (c *InstanceClient) DeleteIntegration(ctx context.Context, kind string, integrationId int) error {
+	err := c.api.delete(
+		ctx,
+		fmt.Sprintf(IntegrationBaseURL, kind, integrationId),
+		nil,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *InstanceClient) getIntegrations(ctx context.Context, kind string) ([]Integration, error) {
+	var integrationsResponse IntegrationsResponse
+
+	err := c.api.get(
+		ctx,
+		fmt.Sprintf(IntegrationsBaseURL, kind),
+		&integrationsResponse,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range integrationsResponse {
+		integrationsResponse[i].Kind = kind
+	}
+
+	return integrationsResponse, nil
+}
+
+// GetPlugins returns all RabbitMQ plugins available on the instance.
+func (c *InstanceClient) GetPlugins(ctx context.Context) ([]Plugin, error) {
+	var pluginsResponse PluginsResponse
+
+	err := c.api.get(
+		ctx,
+		PluginsBaseURL,
+		&pluginsResponse,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pluginsResponse, nil
+}
+
+func NewEnablePluginPayload(name string) url.Values {
+	payload := url.Values{}
+
+	payload.Set("name", name)
+
+	return payload
+}
+
+// EnablePlugin enables provided plugin on the instance.
+func (c *InstanceClient) EnablePlugin(ctx context.Context, name string) error {
+	err := c.api.post(
+		ctx,
+		PluginsBaseURL,
+		NewEnablePluginPayload(name),
+		nil,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DisablePlugin disables provided plugin on the instance.
+func (c *InstanceClient) DisablePlugin(ctx context.Context, name string) error {
+	err := c.api.delete(
+		ctx,
+		fmt.Sprintf(PluginBaseURL, url.PathEscape(name)),
+		nil,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,172 @@
+package cloudamqp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// LavinMQClient talks to the management HTTP API of a LavinMQ broker. The API follows the RabbitMQ one, but
+// LavinMQ does not export definitions the same way and leaves out some endpoints, so definitions are assembled
+// from the listing endpoints and features the broker does not offer are left empty.
+type LavinMQClient struct {
+	management *ManagementClient
+}
+
+func NewLavinMQClient(management *ManagementClient) *LavinMQClient {
+	return &LavinMQClient{
+		management: management,
+	}
+}
+
+// Engine returns the message broker behind the API.
+func (c *LavinMQClient) Engine() string {
+	return BrokerEngineLavinMQ
+}
+
+// GetDefinitions lists users, vhosts and permissions of the broker, then whatever it supports of queues,
+// exchanges, policies, operator policies and parameters.
+func (c *LavinMQClient) GetDefinitions(ctx context.Context) (*Definitions, error) {
+	users, err := c.management.GetUsers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	vhosts, err := c.management.GetVhosts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list vhosts: %w", err)
+	}
+
+	definitions := &Definitions{
+		Users:  users,
+		Vhosts: vhosts,
+	}
+
+	for _, vhost := range vhosts {
+		permissions, err := c.management.GetVhostPermissions(ctx, vhost.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list permissions of vhost %s: %w", vhost.Name, err)
+		}
+
+		for _, permission := range permissions {
+			// permissions are listed per vhost, some versions leave the vhost out of each of them
+			if permission.Vhost == "" {
+				permission.Vhost = vhost.Name
+			}
+
+			definitions.Permissions = append(definitions.Permissions, permission)
+		}
+
+		queues, err := c.management.GetQueues(ctx, vhost.Name)
+		if err := optional(err); err != nil {
+			return nil, fmt.Errorf("failed to list queues of vhost %s: %w", vhost.Name, err)
+		}
+		definitions.Queues = append(definitions.Queues, queues...)
+
+		exchanges, err := c.management.GetExchanges(ctx, vhost.Name)
+		if err := optional(err); err != nil {
+			return nil, fmt.Errorf("failed to list exchanges of vhost %s: %w", vhost.Name, err)
+		}
+
+		for _, exchange := range exchanges {
+			// built-in exchanges are added to every vhost by the definitions, like RabbitMQ exports leave them out
+			if exchange.Name == "" || strings.HasPrefix(exchange.Name, "amq.") {
+				continue
+			}
+
+			definitions.Exchanges = append(definitions.Exchanges, exchange)
+		}
+	}
+
+	policies, err := c.management.GetPolicies(ctx)
+	if err := optional(err); err != nil {
+		return nil, fmt.Errorf("failed to list policies: %w", err)
+	}
+	definitions.Policies = policies
+
+	operatorPolicies, err := c.management.GetOperatorPolicies(ctx)
+	if err := optional(err); err != nil {
+		return nil, fmt.Errorf("failed to list operator policies: %w", err)
+	}
+	definitions.OperatorPolicies = operatorPolicies
+
+	parameters, err := c.management.GetParameters(ctx)
+	if err := optional(err); err != nil {
+		return nil, fmt.Errorf("failed to list parameters: %w", err)
+	}
+	definitions.Parameters = parameters
+
+	return definitions, nil
+}
+
+// GetConnections returns all client connections currently open to the broker.
+func (c *LavinMQClient) GetConnections(ctx context.Context) ([]Connection, error) {
+	connections, err := c.management.GetConnections(ctx)
+	if err != nil {
+		if isUnsupportedEndpoint(err) {
+			return nil, fmt.Errorf("listing connections is %w", ErrUnsupportedByBroker)
+		}
+
+		return nil, err
+	}
+
+	return connections, nil
+}
+
+// GetUserLimits returns limits of all broker users having some.
+func (c *LavinMQClient) GetUserLimits(ctx context.Context) ([]UserLimits, error) {
+	limits, err := c.management.GetUserLimits(ctx)
+	if err != nil {
+		return nil, unsupported("user limits", err)
+	}
+
+	return limits, nil
+}
+
+// SetUserLimit sets a limit of provided broker user.
+func (c *LavinMQClient) SetUserLimit(ctx context.Context, username string, name string, value int64) error {
+	return unsupported("user limits", c.management.SetUserLimit(ctx, username, name, value))
+}
+
+// ClearUserLimit removes a limit of provided broker user.
+func (c *LavinMQClient) ClearUserLimit(ctx context.Context, username string, name string) error {
+	return unsupported("user limits", c.management.ClearUserLimit(ctx, username, name))
+}
+
+// GetVhostLimits returns limits of all virtual hosts having some.
+func (c *LavinMQClient) GetVhostLimits(ctx context.Context) ([]VhostLimits, error) {
+	limits, err := c.management.GetVhostLimits(ctx)
+	if err != nil {
+		return nil, unsupported("vhost limits", err)
+	}
+
+	return limits, nil
+}
+
+// SetVhostLimit sets a limit of provided virtual host.
+func (c *LavinMQClient) SetVhostLimit(ctx context.Context, vhost string, name string, value int64) error {
+	return unsupported("vhost limits", c.management.SetVhostLimit(ctx, vhost, name, value))
+}
+
+// ClearVhostLimit removes a limit of provided virtual host.
+func (c *LavinMQClient) ClearVhostLimit(ctx context.Context, vhost string, name string) error {
+	return unsupported("vhost limits", c.management.ClearVhostLimit(ctx, vhost, name))
+}
+
+// unsupported reports errors of endpoints the broker does not implement as unsupported features.
+func unsupported(feature string, err error) error {
+	if err != nil && isUnsupportedEndpoint(err) {
+		return fmt.Errorf("%s are %w", feature, ErrUnsupportedByBroker)
+	}
+
+	return err
+}
+
+// optional ignores errors of endpoints the broker does not implement.
+func optional(err error) error {
+	if err != nil && isUnsupportedEndpoint(err) {
+		return nil
+	}
+
+	return err
+}
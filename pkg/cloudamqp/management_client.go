@@ -0,0 +1,410 @@
+package cloudamqp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const ManagementDefinitionsURL = "%s/definitions"
+const ManagementUsersURL = "%s/users"
+const ManagementUserURL = "%s/users/%s"
+const ManagementUserPermissionsURL = "%s/users/%s/permissions"
+const ManagementConnectionsURL = "%s/connections"
+const ManagementVhostsURL = "%s/vhosts"
+const ManagementVhostURL = "%s/vhosts/%s"
+const ManagementVhostPermissionsURL = "%s/vhosts/%s/permissions"
+const ManagementQueuesURL = "%s/queues/%s"
+const ManagementExchangesURL = "%s/exchanges/%s"
+const ManagementParametersURL = "%s/parameters"
+const ManagementPoliciesURL = "%s/policies"
+const ManagementOperatorPoliciesURL = "%s/operator-policies"
+const ManagementUserLimitsURL = "%s/user-limits"
+const ManagementUserLimitURL = "%s/user-limits/%s/%s"
+const ManagementVhostLimitsURL = "%s/vhost-limits"
+const ManagementVhostLimitURL = "%s/vhost-limits/%s/%s"
+
+// ManagementClient talks to the management HTTP API of the broker running on a CloudAMQP instance.
+type ManagementClient struct {
+	api     *Client
+	baseURL string
+}
+
+type BrokerUsersResponse = []BrokerUser
+type VhostsResponse = []Vhost
+type PermissionsResponse = []Permission
+type QueuesResponse = []Queue
+type ExchangesResponse = []Exchange
+type ConnectionsResponse = []Connection
+type ParametersResponse = []Parameter
+type PoliciesResponse = []Policy
+type UserLimitsResponse = []UserLimits
+type VhostLimitsResponse = []VhostLimits
+
+type limitPayload struct {
+	Value int64 `json:"value"`
+}
+
+func NewManagementClient(httpClient *http.Client, baseURL string, username string, password string) *ManagementClient {
+	api := NewClient(httpClient, password)
+	api.username = username
+
+	return &ManagementClient{
+		api:     api,
+		baseURL: baseURL,
+	}
+}
+
+// NewManagementClientFromURL creates a management client from the AMQP URL of an instance,
+// which carries the broker host and the credentials of the instance's default user.
+func NewManagementClientFromURL(httpClient *http.Client, amqpURL string) (*ManagementClient, error) {
+	parsedURL, err := url.Parse(amqpURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid instance url: %w", err)
+	}
+
+	if parsedURL.User == nil || parsedURL.Host == "" {
+		return nil, fmt.Errorf("instance url has no broker credentials")
+	}
+
+	password, _ := parsedURL.User.Password()
+	baseURL := fmt.Sprintf("https://%s/api", parsedURL.Hostname())
+
+	return NewManagementClient(httpClient, baseURL, parsedURL.User.Username(), password), nil
+}
+
+// Engine returns the message broker behind the API.
+func (c *ManagementClient) Engine() string {
+	return BrokerEngineRabbitMQ
+}
+
+// GetDefinitions exports users, vhosts, permissions, policies, queues and exchanges of the broker in a single request.
+// Operator policies are left out of exports, they are listed on their own when the broker supports them.
+func (c *ManagementClient) GetDefinitions(ctx context.Context) (*Definitions, error) {
+	var definitionsResponse json.RawMessage
+
+	err := c.api.get(
+		ctx,
+		fmt.Sprintf(ManagementDefinitionsURL, c.baseURL),
+		&definitionsResponse,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	definitions, err := ParseDefinitions(definitionsResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	operatorPolicies, err := c.GetOperatorPolicies(ctx)
+	if err := optional(err); err != nil {
+		return nil, fmt.Errorf("failed to list operator policies: %w", err)
+	}
+	definitions.OperatorPolicies = operatorPolicies
+
+	return definitions, nil
+}
+
+// GetUsers returns all users of the broker.
+func (c *ManagementClient) GetUsers(ctx context.Context) ([]BrokerUser, error) {
+	var usersResponse BrokerUsersResponse
+
+	err := c.api.get(
+		ctx,
+		fmt.Sprintf(ManagementUsersURL, c.baseURL),
+		&usersResponse,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return usersResponse, nil
+}
+
+// GetUser returns provided user of the broker.
+func (c *ManagementClient) GetUser(ctx context.Context, username string) (*BrokerUser, error) {
+	var userResponse BrokerUser
+
+	err := c.api.get(
+		ctx,
+		fmt.Sprintf(ManagementUserURL, c.baseURL, url.PathEscape(username)),
+		&userResponse,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &userResponse, nil
+}
+
+// GetUserPermissions returns permissions of provided user in all virtual hosts.
+func (c *ManagementClient) GetUserPermissions(ctx context.Context, username string) ([]Permission, error) {
+	var permissionsResponse PermissionsResponse
+
+	err := c.api.get(
+		ctx,
+		fmt.Sprintf(ManagementUserPermissionsURL, c.baseURL, url.PathEscape(username)),
+		&permissionsResponse,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return permissionsResponse, nil
+}
+
+// GetVhost returns provided virtual host of the broker.
+func (c *ManagementClient) GetVhost(ctx context.Context, vhost string) (*Vhost, error) {
+	var vhostResponse Vhost
+
+	err := c.api.get(
+		ctx,
+		fmt.Sprintf(ManagementVhostURL, c.baseURL, url.PathEscape(vhost)),
+		&vhostResponse,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &vhostResponse, nil
+}
+
+// GetVhosts returns all virtual hosts of the broker.
+func (c *ManagementClient) GetVhosts(ctx context.Context) ([]Vhost, error) {
+	var vhostsResponse VhostsResponse
+
+	err := c.api.get(
+		ctx,
+		fmt.Sprintf(ManagementVhostsURL, c.baseURL),
+		&vhostsResponse,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return vhostsResponse, nil
+}
+
+// GetVhostPermissions returns permissions of all users in provided virtual host.
+func (c *ManagementClient) GetVhostPermissions(ctx context.Context, vhost string) ([]Permission, error) {
+	var permissionsResponse PermissionsResponse
+
+	err := c.api.get(
+		ctx,
+		fmt.Sprintf(ManagementVhostPermissionsURL, c.baseURL, url.PathEscape(vhost)),
+		&permissionsResponse,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return permissionsResponse, nil
+}
+
+// GetQueues returns all queues in provided virtual host.
+func (c *ManagementClient) GetQueues(ctx context.Context, vhost string) ([]Queue, error) {
+	var queuesResponse QueuesResponse
+
+	err := c.api.get(
+		ctx,
+		fmt.Sprintf(ManagementQueuesURL, c.baseURL, url.PathEscape(vhost)),
+		&queuesResponse,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return queuesResponse, nil
+}
+
+// GetExchanges returns all exchanges in provided virtual host.
+func (c *ManagementClient) GetExchanges(ctx context.Context, vhost string) ([]Exchange, error) {
+	var exchangesResponse ExchangesResponse
+
+	err := c.api.get(
+		ctx,
+		fmt.Sprintf(ManagementExchangesURL, c.baseURL, url.PathEscape(vhost)),
+		&exchangesResponse,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return exchangesResponse, nil
+}
+
+// GetConnections returns all client connections currently open to the broker.
+func (c *ManagementClient) GetConnections(ctx context.Context) ([]Connection, error) {
+	var connectionsResponse ConnectionsResponse
+
+	err := c.api.get(
+		ctx,
+		fmt.Sprintf(ManagementConnectionsURL, c.baseURL),
+		&connectionsResponse,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return connectionsResponse, nil
+}
+
+// GetParameters returns runtime parameters of all virtual hosts, such as dynamic shovels and federation upstreams.
+func (c *ManagementClient) GetParameters(ctx context.Context) ([]Parameter, error) {
+	var parametersResponse ParametersResponse
+
+	err := c.api.get(
+		ctx,
+		fmt.Sprintf(ManagementParametersURL, c.baseURL),
+		&parametersResponse,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return parametersResponse, nil
+}
+
+// GetPolicies returns policies of all virtual hosts.
+func (c *ManagementClient) GetPolicies(ctx context.Context) ([]Policy, error) {
+	var policiesResponse PoliciesResponse
+
+	err := c.api.get(
+		ctx,
+		fmt.Sprintf(ManagementPoliciesURL, c.baseURL),
+		&policiesResponse,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return policiesResponse, nil
+}
+
+// GetOperatorPolicies returns operator policies of all virtual hosts.
+func (c *ManagementClient) GetOperatorPolicies(ctx context.Context) ([]Policy, error) {
+	var policiesResponse PoliciesResponse
+
+	err := c.api.get(
+		ctx,
+		fmt.Sprintf(ManagementOperatorPoliciesURL, c.baseURL),
+		&policiesResponse,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return policiesResponse, nil
+}
+
+// GetUserLimits returns limits of all broker users having some.
+func (c *ManagementClient) GetUserLimits(ctx context.Context) ([]UserLimits, error) {
+	var limitsResponse UserLimitsResponse
+
+	err := c.api.get(
+		ctx,
+		fmt.Sprintf(ManagementUserLimitsURL, c.baseURL),
+		&limitsResponse,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return limitsResponse, nil
+}
+
+// SetUserLimit sets a limit of provided broker user.
+func (c *ManagementClient) SetUserLimit(ctx context.Context, username string, name string, value int64) error {
+	err := c.api.putJSON(
+		ctx,
+		fmt.Sprintf(ManagementUserLimitURL, c.baseURL, url.PathEscape(username), url.PathEscape(name)),
+		&limitPayload{Value: value},
+		nil,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ClearUserLimit removes a limit of provided broker user.
+func (c *ManagementClient) ClearUserLimit(ctx context.Context, username string, name string) error {
+	err := c.api.delete(
+		ctx,
+		fmt.Sprintf(ManagementUserLimitURL, c.baseURL, url.PathEscape(username), url.PathEscape(name)),
+		nil,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetVhostLimits returns limits of all virtual hosts having some.
+func (c *ManagementClient) GetVhostLimits(ctx context.Context) ([]VhostLimits, error) {
+	var limitsResponse VhostLimitsResponse
+
+	err := c.api.get(
+		ctx,
+		fmt.Sprintf(ManagementVhostLimitsURL, c.baseURL),
+		&limitsResponse,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return limitsResponse, nil
+}
+
+// SetVhostLimit sets a limit of provided virtual host.
+func (c *ManagementClient) SetVhostLimit(ctx context.Context, vhost string, name string, value int64) error {
+	err := c.api.putJSON(
+		ctx,
+		fmt.Sprintf(ManagementVhostLimitURL, c.baseURL, url.PathEscape(vhost), url.PathEscape(name)),
+		&limitPayload{Value: value},
+		nil,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ClearVhostLimit removes a limit of provided virtual host.
+func (c *ManagementClient) ClearVhostLimit(ctx context.Context, vhost string, name string) error {
+	err := c.api.delete(
+		ctx,
+		fmt.Sprintf(ManagementVhostLimitURL, c.baseURL, url.PathEscape(vhost), url.PathEscape(name)),
+		nil,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
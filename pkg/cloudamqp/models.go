@@ -1,11 +1,238 @@
 package cloudamqp
 
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
 type BaseResource struct {
 	Id string `json:"id"`
 }
 
+// Account is the team account an API key belongs to.
+type Account struct {
+	Id   AccountID `json:"id"`
+	Name string    `json:"name"`
+}
+
+// AccountID is the ID of a team account, returned either as a number or as a string.
+type AccountID string
+
+func (id *AccountID) UnmarshalJSON(data []byte) error {
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err == nil {
+		*id = AccountID(number.String())
+		return nil
+	}
+
+	var rawID string
+	if err := json.Unmarshal(data, &rawID); err != nil {
+		return err
+	}
+
+	*id = AccountID(rawID)
+
+	return nil
+}
+
 type User struct {
 	BaseResource
 	Email string   `json:"email"`
 	Roles []string `json:"roles"`
 }
+
+type Instance struct {
+	Id         int      `json:"id"`
+	Name       string   `json:"name"`
+	Plan       string   `json:"plan"`
+	Region     string   `json:"region"`
+	Tags       []string `json:"tags"`
+	ProviderId string   `json:"providerid"`
+	VpcId      int      `json:"vpc_id"`
+	Backend    string   `json:"backend,omitempty"`
+	Url        string   `json:"url,omitempty"`
+	ApiKey     string   `json:"apikey,omitempty"`
+}
+
+// Engine returns the message broker running on the instance, instances created before LavinMQ was offered run RabbitMQ.
+func (i *Instance) Engine() string {
+	if strings.EqualFold(i.Backend, BrokerEngineLavinMQ) {
+		return BrokerEngineLavinMQ
+	}
+
+	return BrokerEngineRabbitMQ
+}
+
+// Hostname returns the hostname of the broker, taken from the AMQP URL of the instance.
+func (i *Instance) Hostname() string {
+	parsedURL, err := url.Parse(i.Url)
+	if err != nil {
+		return ""
+	}
+
+	return parsedURL.Hostname()
+}
+
+// DefaultUser returns the broker user the instance was created with, taken from the AMQP URL of the instance.
+func (i *Instance) DefaultUser() string {
+	parsedURL, err := url.Parse(i.Url)
+	if err != nil || parsedURL.User == nil {
+		return ""
+	}
+
+	return parsedURL.User.Username()
+}
+
+type Vpc struct {
+	Id      int      `json:"id"`
+	Name    string   `json:"name"`
+	VpcName string   `json:"vpc_name"`
+	Region  string   `json:"region"`
+	Subnet  string   `json:"subnet"`
+	Tags    []string `json:"tags"`
+}
+
+type VpcPeering struct {
+	Id          string   `json:"id"`
+	Status      string   `json:"status"`
+	PeerVpcId   string   `json:"peer_vpc_id"`
+	PeerOwnerId string   `json:"peer_owner_id"`
+	PeerRegion  string   `json:"peer_region"`
+	PeerSubnets []string `json:"peer_subnets"`
+}
+
+type FirewallRule struct {
+	Ip          string   `json:"ip"`
+	Services    []string `json:"services"`
+	Description string   `json:"description"`
+}
+
+type Alarm struct {
+	Id             int    `json:"id"`
+	Type           string `json:"type"`
+	Enabled        bool   `json:"enabled"`
+	ValueThreshold int    `json:"value_threshold,omitempty"`
+	TimeThreshold  int    `json:"time_threshold,omitempty"`
+	QueueRegex     string `json:"queue_regex,omitempty"`
+	VhostRegex     string `json:"vhost_regex,omitempty"`
+	Recipients     []int  `json:"recipients"`
+}
+
+type AlarmRecipient struct {
+	Id    int    `json:"id"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	Name  string `json:"name"`
+}
+
+type Integration struct {
+	Id     int    `json:"id"`
+	Type   string `json:"type"`
+	Region string `json:"region"`
+	// Kind tells whether the integration ships logs or metrics, it is not part of the API response.
+	Kind string `json:"-"`
+}
+
+type Plugin struct {
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+}
+
+// UserTags are tags of a broker user. Older brokers return them as a comma separated string.
+type UserTags []string
+
+func (t *UserTags) UnmarshalJSON(data []byte) error {
+	var tags []string
+	if err := json.Unmarshal(data, &tags); err == nil {
+		*t = tags
+		return nil
+	}
+
+	var rawTags string
+	if err := json.Unmarshal(data, &rawTags); err != nil {
+		return err
+	}
+
+	*t = nil
+	for _, tag := range strings.Split(rawTags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			*t = append(*t, tag)
+		}
+	}
+
+	return nil
+}
+
+type BrokerUser struct {
+	Name string   `json:"name"`
+	Tags UserTags `json:"tags"`
+}
+
+type Vhost struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Limits broker users and vhosts can have.
+const (
+	LimitMaxConnections = "max-connections"
+	LimitMaxChannels    = "max-channels"
+	LimitMaxQueues      = "max-queues"
+)
+
+// Limits maps limit names to their values. Negative values mean no limit.
+type Limits map[string]int64
+
+// Unlimited tells whether none of the limits is set.
+func (l Limits) Unlimited() bool {
+	for _, value := range l {
+		if value >= 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+type UserLimits struct {
+	User  string `json:"user"`
+	Value Limits `json:"value"`
+}
+
+type VhostLimits struct {
+	Vhost string `json:"vhost"`
+	Value Limits `json:"value"`
+}
+
+type Permission struct {
+	User      string `json:"user"`
+	Vhost     string `json:"vhost"`
+	Configure string `json:"configure"`
+	Write     string `json:"write"`
+	Read      string `json:"read"`
+}
+
+type Queue struct {
+	Name    string `json:"name"`
+	Vhost   string `json:"vhost"`
+	Durable bool   `json:"durable"`
+	Type    string `json:"type"`
+}
+
+type Exchange struct {
+	Name    string `json:"name"`
+	Vhost   string `json:"vhost"`
+	Type    string `json:"type"`
+	Durable bool   `json:"durable"`
+}
+
+type Connection struct {
+	Name        string `json:"name"`
+	User        string `json:"user"`
+	Vhost       string `json:"vhost"`
+	PeerHost    string `json:"peer_host"`
+	ConnectedAt int64  `json:"connected_at"`
+}
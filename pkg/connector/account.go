@@ -0,0 +1,321 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+const accountMemberEntitlement = "member"
+
+var accountChildResourceTypes = []*v2.ResourceType{
+	resourceTypeUser,
+	resourceTypeRole,
+	resourceTypeVpc,
+	resourceTypeInstance,
+}
+
+type accountResourceType struct {
+	resourceType *v2.ResourceType
+	accounts     *accounts
+	protected    *protectedPrincipals
+	auditLog     *AuditLog
+}
+
+func (a *accountResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return a.resourceType
+}
+
+// accountResource creates a new connector resource for a CloudAMQP team account. Only named accounts are parents
+// of their team-level resources, those of an unnamed account keep having no parent.
+func accountResource(accounts *accounts, acc *account, identity *cloudamqp.Account) (*v2.Resource, error) {
+	displayName := acc.name
+	profile := map[string]interface{}{
+		"account_name": acc.name,
+	}
+
+	if identity != nil {
+		profile["account_id"] = string(identity.Id)
+		if identity.Name != "" {
+			profile["account_name"] = identity.Name
+			if displayName == "" {
+				displayName = identity.Name
+			}
+		}
+	}
+
+	if displayName == "" {
+		displayName = "CloudAMQP"
+	}
+
+	var resourceOptions []rs.ResourceOption
+	if accounts.named {
+		for _, childResourceType := range accountChildResourceTypes {
+			resourceOptions = append(resourceOptions, rs.WithAnnotation(&v2.ChildResourceType{ResourceTypeId: childResourceType.Id}))
+		}
+	}
+
+	resource, err := rs.NewAppResource(
+		displayName,
+		resourceTypeAccount,
+		accounts.AccountID(acc),
+		[]rs.AppTraitOption{rs.WithAppProfile(profile)},
+		resourceOptions...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+// List returns the team accounts synced by the connector, along with their name and ID when the API tells them.
+func (a *accountResourceType) List(ctx context.Context, parentID *v2.ResourceId, _ *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentID != nil {
+		return nil, "", nil, nil
+	}
+
+	rv := make([]*v2.Resource, 0, len(a.accounts.list))
+	for _, acc := range a.accounts.list {
+		identity, err := a.accounts.Identity(ctx, acc)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		ar, err := accountResource(a.accounts, acc, identity)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rv = append(rv, ar)
+	}
+
+	return rv, "", nil, nil
+}
+
+// Entitlements returns the member entitlement, held by every user of the team account.
+func (a *accountResourceType) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	return []*v2.Entitlement{
+		ent.NewAssignmentEntitlement(
+			resource,
+			accountMemberEntitlement,
+			ent.WithGrantableTo(resourceTypeUser),
+			ent.WithDisplayName(fmt.Sprintf("%s team member", resource.DisplayName)),
+			ent.WithDescription(fmt.Sprintf("Member of the %s CloudAMQP team", resource.DisplayName)),
+		),
+	}, "", nil, nil
+}
+
+// Grants returns every user of the team. When the team has not changed since the previous sync, its grants are
+// reused instead.
+func (a *accountResourceType) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	acc, err := a.accounts.ForAccountID(resource.Id.Resource)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	users, err := acc.client.GetUsers(ctx)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("cloudamqp-connector: failed to get users: %w", err)
+	}
+
+	annos, unchanged := grantsETag(resource, accountMemberEntitlement, acc.client.UsersVersion(), a.accounts.Fingerprint())
+	if unchanged {
+		return nil, "", annos, nil
+	}
+
+	rv := make([]*v2.Grant, 0, len(users))
+	for _, user := range users {
+		rv = append(rv, grant.NewGrant(
+			resource,
+			accountMemberEntitlement,
+			&v2.ResourceId{
+				ResourceType: resourceTypeUser.Id,
+				Resource:     a.accounts.ScopedID(acc, user.Id),
+			},
+		))
+	}
+
+	return rv, "", annos, nil
+}
+
+// Grant invites the user to the team account, with the member role. Users of another account synced by the
+// connector are invited by their email.
+func (a *accountResourceType) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	if principal.Id.ResourceType != resourceTypeUser.Id {
+		l.Warn(
+			"cloudamqp-connector: only users can be invited to the team",
+			zap.String("principal_id", principal.Id.String()),
+			zap.String("principal_type", principal.Id.ResourceType),
+		)
+
+		return nil, fmt.Errorf("cloudamqp-connector: only users can be invited to the team")
+	}
+
+	acc, err := a.accounts.ForAccountID(entitlement.Resource.Id.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	email, err := userEmail(principal)
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := acc.client.GetUsers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cloudamqp-connector: failed to get users: %w", err)
+	}
+
+	for _, user := range users {
+		if strings.EqualFold(user.Email, email) {
+			l.Info(
+				"cloudamqp-connector: user is already a member of the team",
+				zap.String("email", email),
+			)
+
+			return nil, nil
+		}
+	}
+
+	invited := &cloudamqp.User{Email: email, Roles: []string{roleMember}}
+	err = a.protected.CheckUser(invited)
+	if err != nil {
+		return nil, err
+	}
+
+	change := &auditChange{
+		operation: auditOperationGrant,
+		action:    "invite_user",
+		principal: auditPrincipal(principal.Id),
+		target:    entitlement.Id,
+		after:     invited,
+	}
+
+	err = a.auditLog.track(ctx, change, func() error {
+		return acc.client.InviteUser(ctx, email, roleMember)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cloudamqp-connector: failed to invite user: %w", err)
+	}
+
+	return nil, nil
+}
+
+// Revoke removes the user from the team account.
+func (a *accountResourceType) Revoke(ctx context.Context, grant *v2.Grant) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	principal := grant.Principal
+
+	if principal.Id.ResourceType != resourceTypeUser.Id {
+		l.Warn(
+			"cloudamqp-connector: only users can be removed from the team",
+			zap.String("principal_id", principal.Id.String()),
+			zap.String("principal_type", principal.Id.ResourceType),
+		)
+
+		return nil, fmt.Errorf("cloudamqp-connector: only users can be removed from the team")
+	}
+
+	acc, err := a.accounts.ForAccountID(grant.Entitlement.Resource.Id.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	userAcc, userId, err := a.accounts.ParseScopedID(principal.Id.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	if userAcc != acc {
+		return nil, fmt.Errorf("cloudamqp-connector: user %s is not a member of account %s", principal.Id.Resource, acc.name)
+	}
+
+	users, err := acc.client.GetUsers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cloudamqp-connector: failed to get users: %w", err)
+	}
+
+	var user *cloudamqp.User
+	for i := range users {
+		if users[i].Id == userId {
+			user = &users[i]
+			break
+		}
+	}
+
+	if user == nil {
+		l.Info(
+			"cloudamqp-connector: user is not a member of the team",
+			zap.String("user_id", userId),
+		)
+
+		return nil, nil
+	}
+
+	err = a.protected.CheckUser(user)
+	if err != nil {
+		return nil, err
+	}
+
+	change := &auditChange{
+		operation: auditOperationRevoke,
+		action:    "remove_user",
+		principal: auditPrincipal(principal.Id),
+		target:    grant.Entitlement.Id,
+		before:    user,
+	}
+
+	err = a.auditLog.track(ctx, change, func() error {
+		return acc.client.RemoveUser(ctx, userId)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cloudamqp-connector: failed to remove user: %w", err)
+	}
+
+	return nil, nil
+}
+
+// userEmail returns the primary email of a user resource.
+func userEmail(user *v2.Resource) (string, error) {
+	userTrait, err := rs.GetUserTrait(user)
+	if err != nil {
+		return "", fmt.Errorf("cloudamqp-connector: failed to get user trait: %w", err)
+	}
+
+	for _, email := range userTrait.Emails {
+		if email.IsPrimary && email.Address != "" {
+			return email.Address, nil
+		}
+	}
+
+	for _, email := range userTrait.Emails {
+		if email.Address != "" {
+			return email.Address, nil
+		}
+	}
+
+	return "", fmt.Errorf("cloudamqp-connector: user %s has no email", user.Id.Resource)
+}
+
+func accountBuilder(accounts *accounts, protected *protectedPrincipals, auditLog *AuditLog) *accountResourceType {
+	return &accountResourceType{
+		resourceType: resourceTypeAccount,
+		accounts:     accounts,
+		protected:    protected,
+		auditLog:     auditLog,
+	}
+}
@@ -0,0 +1,462 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// account is a CloudAMQP team account synced by the connector.
+type account struct {
+	name   string
+	client *cloudamqp.Client
+
+	identity *cloudamqp.Account
+	// identityFetched tells whether identity was fetched, as it stays nil when the API does not tell.
+	identityFetched bool
+}
+
+// Resource ID of an unnamed account, which keeps the IDs of its resources unscoped.
+const unnamedAccountID = "team"
+
+// accounts routes requests to the team accounts synced by the connector. Named accounts are synced under an
+// account resource, with IDs of their users, roles and VPCs namespaced by the account name. CloudAMQP instance
+// IDs are unique across accounts, so instances and the resources living on them keep their IDs and requests
+// about them are routed to the account owning the instance. An instance listed by two accounts, such as with two
+// tokens of the same team, is reported as an error rather than routed to either account. Instances left out by the
+// instance filter can not be reached through the instance or management APIs, so that nothing is changed on them.
+type accounts struct {
+	named  bool
+	list   []*account
+	filter *InstanceFilter
+
+	mtx        sync.Mutex
+	byInstance map[int]*account
+	// unowned holds instances none of the accounts listed during the sync, so that they are not listed again.
+	unowned map[int]bool
+	// exclusionsLogged tells whether instances left out by the filter were logged during the sync.
+	exclusionsLogged bool
+
+	// hostnames maps broker hostnames to the instances of every account running them, built once per sync.
+	hostnamesMtx sync.Mutex
+	hostnames    map[string]*cloudamqp.Instance
+}
+
+// newAccounts creates the accounts synced by the connector. A single unnamed account keeps resource IDs as they
+// were before accounts could be named.
+func newAccounts(list []*account, filter *InstanceFilter) *accounts {
+	return &accounts{
+		named:      len(list) != 1 || list[0].name != "",
+		list:       list,
+		filter:     filter,
+		byInstance: make(map[int]*account),
+		unowned:    make(map[int]bool),
+	}
+}
+
+// Reset forgets instances found to belong to none of the accounts, so that the next sync looks for them again, and
+// lets the next sync log the instances it leaves out.
+func (a *accounts) Reset() {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	a.unowned = make(map[int]bool)
+	a.exclusionsLogged = false
+
+	a.hostnamesMtx.Lock()
+	a.hostnames = nil
+	a.hostnamesMtx.Unlock()
+}
+
+// Get returns the account with provided name.
+func (a *accounts) Get(name string) (*account, error) {
+	for _, acc := range a.list {
+		if acc.name == name {
+			return acc, nil
+		}
+	}
+
+	return nil, fmt.Errorf("cloudamqp-connector: unknown account %s", name)
+}
+
+// ScopedID namespaces the ID of a team-level resource by its account, when accounts are named.
+func (a *accounts) ScopedID(acc *account, id string) string {
+	if !a.named {
+		return id
+	}
+
+	return strings.Join([]string{acc.name, id}, idSeparator)
+}
+
+// Fingerprint describes how accounts are configured, which changes the IDs of the resources grants refer to.
+func (a *accounts) Fingerprint() string {
+	if !a.named {
+		return "unnamed"
+	}
+
+	return "named"
+}
+
+// ParseScopedID returns the account of a team-level resource ID and the ID within the account.
+func (a *accounts) ParseScopedID(id string) (*account, string, error) {
+	if !a.named {
+		return a.list[0], id, nil
+	}
+
+	name, accountID, ok := strings.Cut(id, idSeparator)
+	if !ok {
+		return nil, "", fmt.Errorf("cloudamqp-connector: invalid account scoped id %s", id)
+	}
+
+	acc, err := a.Get(name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return acc, accountID, nil
+}
+
+// ForParent returns the accounts whose team-level resources are listed under provided parent. Resources of
+// named accounts live under their account resource, those of an unnamed account have no parent.
+func (a *accounts) ForParent(parentID *v2.ResourceId) ([]*account, error) {
+	if !a.named {
+		if parentID != nil {
+			return nil, nil
+		}
+
+		return a.list, nil
+	}
+
+	if parentID == nil || parentID.ResourceType != resourceTypeAccount.Id {
+		return nil, nil
+	}
+
+	acc, err := a.Get(parentID.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*account{acc}, nil
+}
+
+// AccountID returns the ID of the account resource of provided account.
+func (a *accounts) AccountID(acc *account) string {
+	if !a.named {
+		return unnamedAccountID
+	}
+
+	return acc.name
+}
+
+// ForAccountID returns the account of provided account resource ID.
+func (a *accounts) ForAccountID(id string) (*account, error) {
+	if !a.named {
+		if id != unnamedAccountID {
+			return nil, fmt.Errorf("cloudamqp-connector: unknown account %s", id)
+		}
+
+		return a.list[0], nil
+	}
+
+	return a.Get(id)
+}
+
+// Identity returns the team account provided account's API key belongs to, or nil when the API does not tell. The
+// identity is fetched once, including when the API does not tell, so that it is not requested again on every sync.
+func (a *accounts) Identity(ctx context.Context, acc *account) (*cloudamqp.Account, error) {
+	a.mtx.Lock()
+	identity, fetched := acc.identity, acc.identityFetched
+	a.mtx.Unlock()
+	if fetched {
+		return identity, nil
+	}
+
+	identity, err := acc.client.GetAccount(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cloudamqp-connector: failed to get account: %w", err)
+	}
+
+	a.mtx.Lock()
+	acc.identity, acc.identityFetched = identity, true
+	a.mtx.Unlock()
+
+	return identity, nil
+}
+
+// ResourceID returns the ID of the parent account resource of team-level resources, or nil when accounts are not
+// named.
+func (a *accounts) ResourceID(acc *account) *v2.ResourceId {
+	if !a.named {
+		return nil
+	}
+
+	return &v2.ResourceId{
+		ResourceType: resourceTypeAccount.Id,
+		Resource:     acc.name,
+	}
+}
+
+// userResource creates a new connector resource for a user of provided account.
+func (a *accounts) userResource(ctx context.Context, acc *account, user *cloudamqp.User, protected bool) (*v2.Resource, error) {
+	return userResource(ctx, user, a.ScopedID(acc, user.Id), a.ResourceID(acc), protected)
+}
+
+// Register remembers which account owns provided instances, failing when another account owns one of them.
+func (a *accounts) Register(acc *account, instances []cloudamqp.Instance) error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	for _, instance := range instances {
+		if owner, ok := a.byInstance[instance.Id]; ok && owner != acc {
+			return fmt.Errorf(
+				"cloudamqp-connector: instance %d is listed by accounts %s and %s, which must be different teams",
+				instance.Id,
+				owner.name,
+				acc.name,
+			)
+		}
+
+		a.byInstance[instance.Id] = acc
+		delete(a.unowned, instance.Id)
+	}
+
+	return nil
+}
+
+// ForInstance returns the account owning provided instance, listing instances of every account on first use.
+// Instances none of the accounts own are remembered until the next sync, so that looking them up again does not
+// list instances of every account.
+func (a *accounts) ForInstance(ctx context.Context, instanceID int) (*account, error) {
+	if !a.named {
+		return a.list[0], nil
+	}
+
+	a.mtx.Lock()
+	acc, ok := a.byInstance[instanceID]
+	unowned := a.unowned[instanceID]
+	a.mtx.Unlock()
+	if ok {
+		return acc, nil
+	}
+
+	if !unowned {
+		for _, acc := range a.list {
+			instances, err := acc.client.GetInstances(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("cloudamqp-connector: failed to list instances of account %s: %w", acc.name, err)
+			}
+
+			if err := a.Register(acc, instances); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	acc, ok = a.byInstance[instanceID]
+	if !ok {
+		a.unowned[instanceID] = true
+		return nil, fmt.Errorf("cloudamqp-connector: instance %d belongs to none of the accounts", instanceID)
+	}
+
+	return acc, nil
+}
+
+// Client returns the client of the account owning provided instance.
+func (a *accounts) Client(ctx context.Context, instanceID int) (*cloudamqp.Client, error) {
+	acc, err := a.ForInstance(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return acc.client, nil
+}
+
+// Excludes returns why provided instance, listed by provided account, is left out by the instance filter, or an empty
+// string when it is synced. Listed instances may lack their engine: the filter is then applied to the details of the
+// instance, as when the instance is reached.
+func (a *accounts) Excludes(ctx context.Context, acc *account, instance *cloudamqp.Instance) (string, error) {
+	if a.filter.needsDetails(instance) {
+		details, err := acc.client.Instance(ctx, instance.Id)
+		if err != nil {
+			return "", fmt.Errorf("cloudamqp-connector: failed to get instance %d: %w", instance.Id, err)
+		}
+		instance = details
+	}
+
+	return a.filter.Excludes(instance), nil
+}
+
+// LogExclusions logs a single summary of the instances of every account left out by the instance filter, once per
+// sync.
+func (a *accounts) LogExclusions(ctx context.Context) error {
+	if a.filter == nil {
+		return nil
+	}
+
+	a.mtx.Lock()
+	logged := a.exclusionsLogged
+	a.exclusionsLogged = true
+	a.mtx.Unlock()
+	if logged {
+		return nil
+	}
+
+	var (
+		synced   int
+		excluded []string
+	)
+	for _, acc := range a.list {
+		instances, err := acc.client.GetInstances(ctx)
+		if err != nil {
+			a.mtx.Lock()
+			a.exclusionsLogged = false
+			a.mtx.Unlock()
+
+			return fmt.Errorf("cloudamqp-connector: failed to list instances: %w", err)
+		}
+
+		for idx := range instances {
+			instance := &instances[idx]
+			reason, err := a.Excludes(ctx, acc, instance)
+			if err != nil {
+				a.mtx.Lock()
+				a.exclusionsLogged = false
+				a.mtx.Unlock()
+
+				return err
+			}
+
+			if reason != "" {
+				excluded = append(excluded, fmt.Sprintf("%s (%d): %s", instance.Name, instance.Id, reason))
+				continue
+			}
+			synced++
+		}
+	}
+
+	if len(excluded) > 0 {
+		ctxzap.Extract(ctx).Info(
+			"cloudamqp-connector: instances excluded from sync",
+			zap.Int("synced", synced),
+			zap.Int("excluded", len(excluded)),
+			zap.Strings("excluded_instances", excluded),
+		)
+	}
+
+	return nil
+}
+
+// includedClient returns the client of the account owning provided instance, failing when the instance is left out
+// by the instance filter.
+func (a *accounts) includedClient(ctx context.Context, instanceID int) (*cloudamqp.Client, error) {
+	client, err := a.Client(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.filter == nil {
+		return client, nil
+	}
+
+	instance, err := client.Instance(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if reason := a.filter.Excludes(instance); reason != "" {
+		return nil, fmt.Errorf("cloudamqp-connector: instance %d is excluded from sync (%s)", instanceID, reason)
+	}
+
+	return client, nil
+}
+
+// InstanceClient returns a client for the instance API of provided instance.
+func (a *accounts) InstanceClient(ctx context.Context, instanceID int) (*cloudamqp.InstanceClient, error) {
+	client, err := a.includedClient(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.InstanceClient(ctx, instanceID)
+}
+
+// Broker returns a client for the management API of the broker running on provided instance.
+func (a *accounts) Broker(ctx context.Context, instanceID int) (cloudamqp.Broker, error) {
+	client, err := a.includedClient(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Broker(ctx, instanceID)
+}
+
+// InstanceHostname returns the hostname of the broker running on provided instance.
+func (a *accounts) InstanceHostname(ctx context.Context, instanceID int) (string, error) {
+	client, err := a.Client(ctx, instanceID)
+	if err != nil {
+		return "", err
+	}
+
+	return client.InstanceHostname(ctx, instanceID)
+}
+
+// InstanceDefaultUser returns the broker user provided instance was created with.
+func (a *accounts) InstanceDefaultUser(ctx context.Context, instanceID int) (string, error) {
+	client, err := a.Client(ctx, instanceID)
+	if err != nil {
+		return "", err
+	}
+
+	return client.InstanceDefaultUser(ctx, instanceID)
+}
+
+// InstanceByHostname returns the instance of any account running the broker with provided hostname, or nil when
+// there is none or it is left out by the instance filter. Hostnames of the instances are looked up once per sync.
+func (a *accounts) InstanceByHostname(ctx context.Context, hostname string) (*cloudamqp.Instance, error) {
+	a.hostnamesMtx.Lock()
+	defer a.hostnamesMtx.Unlock()
+
+	if a.hostnames == nil {
+		hostnames := make(map[string]*cloudamqp.Instance)
+		for _, acc := range a.list {
+			instances, err := acc.client.GetInstances(ctx)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, listedInstance := range instances {
+				instance, err := acc.client.Instance(ctx, listedInstance.Id)
+				if err != nil {
+					return nil, err
+				}
+
+				if a.filter.Excludes(instance) == "" {
+					hostnames[strings.ToLower(instance.Hostname())] = instance
+				}
+			}
+		}
+
+		a.hostnames = hostnames
+	}
+
+	return a.hostnames[strings.ToLower(hostname)], nil
+}
+
+// EventSubscriber returns a subscriber to access events of the broker running on provided instance.
+func (a *accounts) EventSubscriber(ctx context.Context, instanceID int, opts ...cloudamqp.EventSubscriberOption) (*cloudamqp.EventSubscriber, error) {
+	client, err := a.includedClient(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.EventSubscriber(ctx, instanceID, opts...)
+}
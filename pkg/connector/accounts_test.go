@@ -0,0 +1,40 @@
+package connector
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
+)
+
+func TestIdentityNotSupportedFetchedOnce(t *testing.T) {
+	requests := 0
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.String() != cloudamqp.AccountURL {
+			t.Errorf("unexpected request %s %s", req.Method, req.URL)
+		}
+		requests++
+
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}}, nil
+	})
+	acc := &account{name: "prod", client: cloudamqp.NewClient(&http.Client{Transport: transport}, "token")}
+	accounts := newAccounts([]*account{acc}, nil)
+
+	for i := 0; i < 3; i++ {
+		identity, err := accounts.Identity(context.Background(), acc)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if identity != nil {
+			t.Fatalf("identity = %+v, want none", identity)
+		}
+	}
+
+	if requests != 1 {
+		t.Errorf("account requested %d times, want once", requests)
+	}
+}
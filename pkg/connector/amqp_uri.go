@@ -0,0 +1,153 @@
+package connector
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// amqpEndpoint is a broker that a shovel or federation upstream connects to, parsed from its AMQP URI.
+type amqpEndpoint struct {
+	uri            string
+	host           string
+	user           string
+	inlinePassword bool
+	brokerUserID   string
+}
+
+// parseAMQPEndpoint parses an AMQP URI, redacting its password. An URI without a host points at the local broker.
+func parseAMQPEndpoint(uri string, localHostname string) *amqpEndpoint {
+	parsedURL, err := url.Parse(uri)
+	if err != nil {
+		return &amqpEndpoint{uri: "unparsable uri"}
+	}
+
+	endpoint := &amqpEndpoint{
+		uri:  uri,
+		host: parsedURL.Hostname(),
+	}
+
+	if endpoint.host == "" {
+		endpoint.host = localHostname
+	}
+
+	if parsedURL.User != nil {
+		endpoint.user = parsedURL.User.Username()
+		_, endpoint.inlinePassword = parsedURL.User.Password()
+	}
+
+	if endpoint.inlinePassword {
+		endpoint.uri = parsedURL.Redacted()
+	}
+
+	return endpoint
+}
+
+func (e *amqpEndpoint) profile() map[string]interface{} {
+	return map[string]interface{}{
+		"uri":             e.uri,
+		"host":            e.host,
+		"user":            e.user,
+		"inline_password": e.inlinePassword,
+	}
+}
+
+// amqpEndpointResolver parses AMQP URIs of one instance and links them to the broker users they authenticate as.
+type amqpEndpointResolver struct {
+	accounts      *accounts
+	instanceID    int
+	localHostname string
+}
+
+func newAMQPEndpointResolver(ctx context.Context, accounts *accounts, instanceID int) (*amqpEndpointResolver, error) {
+	localHostname, err := accounts.InstanceHostname(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &amqpEndpointResolver{
+		accounts:      accounts,
+		instanceID:    instanceID,
+		localHostname: localHostname,
+	}, nil
+}
+
+// Resolve parses provided URIs, linking every one to the broker user resource it authenticates as
+// when the URI points at a synced broker of the accounts.
+func (r *amqpEndpointResolver) Resolve(ctx context.Context, uris []string) []*amqpEndpoint {
+	l := ctxzap.Extract(ctx)
+
+	rv := make([]*amqpEndpoint, 0, len(uris))
+	for _, uri := range uris {
+		endpoint := parseAMQPEndpoint(uri, r.localHostname)
+		rv = append(rv, endpoint)
+
+		if endpoint.user == "" {
+			continue
+		}
+
+		if strings.EqualFold(endpoint.host, r.localHostname) {
+			endpoint.brokerUserID = brokerUserResourceID(r.instanceID, endpoint.user).Resource
+			continue
+		}
+
+		instance, err := r.accounts.InstanceByHostname(ctx, endpoint.host)
+		if err != nil {
+			l.Warn(
+				"cloudamqp-connector: failed to look up instance of amqp uri",
+				zap.String("host", endpoint.host),
+				zap.Error(err),
+			)
+
+			continue
+		}
+
+		if instance != nil {
+			endpoint.brokerUserID = brokerUserResourceID(instance.Id, endpoint.user).Resource
+		}
+	}
+
+	return rv
+}
+
+// endpointGrants grants the connect entitlement of the broker users provided endpoints authenticate as to the shovel or
+// federation upstream connecting through them.
+func endpointGrants(principal *v2.Resource, endpoints []*amqpEndpoint) []*v2.Grant {
+	var rv []*v2.Grant
+	granted := make(map[string]bool)
+	for _, endpoint := range endpoints {
+		if endpoint.brokerUserID == "" || granted[endpoint.brokerUserID] {
+			continue
+		}
+		granted[endpoint.brokerUserID] = true
+
+		brokerUser := &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeBrokerUser.Id, Resource: endpoint.brokerUserID}}
+		rv = append(rv, grant.NewGrant(
+			brokerUser,
+			brokerUserConnect,
+			principal.Id,
+			grant.WithGrantMetadata(map[string]interface{}{
+				"uri": endpoint.uri,
+			}),
+		))
+	}
+
+	return rv
+}
+
+// endpointsProfile returns profiles of provided endpoints and whether any of them carries an inline password.
+func endpointsProfile(endpoints []*amqpEndpoint) ([]interface{}, bool) {
+	inlinePassword := false
+	rv := make([]interface{}, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		rv = append(rv, endpoint.profile())
+		inlinePassword = inlinePassword || endpoint.inlinePassword
+	}
+
+	return rv, inlinePassword
+}
@@ -0,0 +1,346 @@
+package connector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"sync"
+	"syscall"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// Results of audited changes.
+const (
+	AuditResultSuccess = "success"
+	AuditResultFailure = "failure"
+	AuditResultDryRun  = "dry_run"
+)
+
+// Operations audited changes are made for.
+const (
+	auditOperationGrant     = "grant"
+	auditOperationRevoke    = "revoke"
+	auditOperationReconcile = "reconcile"
+)
+
+// Audit log lines hold whole states before and after a change, allow them to be larger than the scanner default.
+const auditMaxLineSize = 16 * 1024 * 1024
+
+// AuditCaller tells who made an audited change.
+type AuditCaller struct {
+	Host   string `json:"host"`
+	User   string `json:"user"`
+	PID    int    `json:"pid"`
+	DryRun bool   `json:"dry_run"`
+}
+
+// AuditEntry is a line of the audit log. Hash covers the entry with an empty hash, including the hash of the
+// previous entry, so that any edit, insertion or removal of entries breaks the chain.
+type AuditEntry struct {
+	Sequence   int64           `json:"sequence"`
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt time.Time       `json:"finished_at"`
+	Operation  string          `json:"operation"`
+	Action     string          `json:"action"`
+	Principal  string          `json:"principal"`
+	Target     string          `json:"target"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	Caller     AuditCaller     `json:"caller"`
+	Result     string          `json:"result"`
+	Error      string          `json:"error,omitempty"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
+
+// auditChange describes a change about to be made.
+type auditChange struct {
+	operation string
+	action    string
+	principal string
+	target    string
+	before    interface{}
+	after     interface{}
+}
+
+// AuditLog is an append-only, hash-chained JSONL log of every change provisioning makes. A nil log audits nothing.
+// Several processes may append to the same log: each append holds an exclusive lock on the file while it reads the
+// last entry and chains the new one to it.
+type AuditLog struct {
+	path   string
+	caller AuditCaller
+
+	mtx sync.Mutex
+}
+
+func NewAuditLog(path string, dryRun bool) *AuditLog {
+	caller := AuditCaller{
+		PID:    os.Getpid(),
+		DryRun: dryRun,
+	}
+
+	if host, err := os.Hostname(); err == nil {
+		caller.Host = host
+	}
+
+	if currentUser, err := user.Current(); err == nil {
+		caller.User = currentUser.Username
+	}
+
+	return &AuditLog{
+		path:   path,
+		caller: caller,
+	}
+}
+
+// track makes a change and records it along with its result. A change made but failing to be recorded is
+// reported as an error, as it would otherwise go missing from the evidence.
+func (a *AuditLog) track(ctx context.Context, change *auditChange, apply func() error) error {
+	if a == nil {
+		return apply()
+	}
+
+	startedAt := time.Now().UTC()
+	err := apply()
+
+	entry := &AuditEntry{
+		StartedAt:  startedAt,
+		FinishedAt: time.Now().UTC(),
+		Operation:  change.operation,
+		Action:     change.action,
+		Principal:  change.principal,
+		Target:     change.target,
+		Caller:     a.caller,
+		Result:     AuditResultSuccess,
+	}
+
+	switch {
+	case err != nil:
+		entry.Result = AuditResultFailure
+		entry.Error = err.Error()
+	case a.caller.DryRun:
+		entry.Result = AuditResultDryRun
+	}
+
+	recordErr := a.record(entry, change)
+	if recordErr != nil {
+		ctxzap.Extract(ctx).Error("cloudamqp-connector: failed to record change in audit log", zap.Error(recordErr))
+
+		if err == nil {
+			return fmt.Errorf("cloudamqp-connector: change made but not recorded in audit log: %w", recordErr)
+		}
+	}
+
+	return err
+}
+
+func (a *AuditLog) record(entry *AuditEntry, change *auditChange) error {
+	var err error
+	if change.before != nil {
+		if entry.Before, err = json.Marshal(change.before); err != nil {
+			return err
+		}
+	}
+
+	if change.after != nil {
+		if entry.After, err = json.Marshal(change.after); err != nil {
+			return err
+		}
+	}
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	file, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return fmt.Errorf("cloudamqp-connector: failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("cloudamqp-connector: failed to lock audit log: %w", err)
+	}
+	defer func() {
+		_ = syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+	}()
+
+	last, err := lastAuditEntry(file)
+	if err != nil {
+		return fmt.Errorf("cloudamqp-connector: failed to read audit log: %w", err)
+	}
+
+	entry.Sequence = 1
+	if last != nil {
+		entry.Sequence, entry.PrevHash = last.Sequence+1, last.Hash
+	}
+
+	entry.Hash, err = auditEntryHash(entry)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("cloudamqp-connector: failed to write audit log: %w", err)
+	}
+
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("cloudamqp-connector: failed to write audit log: %w", err)
+	}
+
+	return nil
+}
+
+// lastAuditEntry returns the last entry of an audit log, or nil when the log is empty. The log is read backwards from
+// its end, so that appending costs the same however long the log is.
+func lastAuditEntry(file *os.File) (*AuditEntry, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		line   []byte
+		chunk  = make([]byte, 64*1024)
+		offset = info.Size()
+	)
+	for offset > 0 {
+		size := int64(len(chunk))
+		if offset < size {
+			size = offset
+		}
+		offset -= size
+
+		if _, err := file.ReadAt(chunk[:size], offset); err != nil {
+			return nil, err
+		}
+		line = append(append([]byte{}, chunk[:size]...), line...)
+
+		trimmed := bytes.TrimRight(line, "\n")
+		if idx := bytes.LastIndexByte(trimmed, '\n'); idx >= 0 {
+			line = trimmed[idx+1:]
+			break
+		}
+
+		if len(trimmed) > auditMaxLineSize {
+			return nil, fmt.Errorf("last audit entry is larger than %d bytes", auditMaxLineSize)
+		}
+	}
+
+	line = bytes.TrimRight(line, "\n")
+	if len(line) == 0 {
+		return nil, nil
+	}
+
+	entry := &AuditEntry{}
+	if err := json.Unmarshal(line, entry); err != nil {
+		return nil, fmt.Errorf("invalid last audit entry: %w", err)
+	}
+
+	return entry, nil
+}
+
+// VerifyAuditLog checks the hash chain of an audit log and returns how many entries it holds and the hash of its
+// last entry. The chain can not tell entries removed from the end of the log, so when a head is provided, an entry
+// with this hash, recorded from an earlier verification, must still be in the log.
+func VerifyAuditLog(path string, head string) (int, string, error) {
+	var (
+		count    int
+		sequence int64
+		lastHash string
+		anchored = head == ""
+	)
+
+	err := readAuditLog(path, func(line int, entry *AuditEntry) error {
+		if entry.Sequence != sequence+1 {
+			return fmt.Errorf("line %d: expected entry %d, found entry %d", line, sequence+1, entry.Sequence)
+		}
+
+		if entry.PrevHash != lastHash {
+			return fmt.Errorf("line %d: entry %d does not follow the previous entry", line, entry.Sequence)
+		}
+
+		hash, err := auditEntryHash(entry)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if hash != entry.Hash {
+			return fmt.Errorf("line %d: entry %d was modified", line, entry.Sequence)
+		}
+
+		count++
+		sequence, lastHash = entry.Sequence, entry.Hash
+		anchored = anchored || entry.Hash == head
+
+		return nil
+	})
+	if err != nil {
+		return count, lastHash, err
+	}
+
+	if !anchored {
+		return count, lastHash, fmt.Errorf("no entry has head hash %s, entries were removed from the end of the log", head)
+	}
+
+	return count, lastHash, nil
+}
+
+func readAuditLog(path string, visit func(line int, entry *AuditEntry) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), auditMaxLineSize)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		entry := &AuditEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			return fmt.Errorf("line %d: invalid audit entry: %w", line, err)
+		}
+
+		if err := visit(line, entry); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+func auditEntryHash(entry *AuditEntry) (string, error) {
+	unhashed := *entry
+	unhashed.Hash = ""
+
+	data, err := json.Marshal(&unhashed)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func auditPrincipal(id *v2.ResourceId) string {
+	return fmt.Sprintf("%s:%s", id.ResourceType, id.Resource)
+}
@@ -0,0 +1,196 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// writeAuditLog records n changes in a new audit log and returns its path and lines.
+func writeAuditLog(t *testing.T, n int) (string, []string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	auditLog := NewAuditLog(path, false)
+
+	for i := 0; i < n; i++ {
+		change := &auditChange{
+			operation: auditOperationGrant,
+			action:    "add_user_role",
+			principal: "user:42",
+			target:    "role:member:member",
+			before:    map[string]int{"change": i},
+		}
+
+		err := auditLog.track(context.Background(), change, func() error {
+			if i == 1 {
+				return errors.New("failed")
+			}
+			return nil
+		})
+		if i != 1 && err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path, strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func rewriteAuditLog(t *testing.T, path string, lines []string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuditLogRoundTrip(t *testing.T) {
+	path, lines := writeAuditLog(t, 3)
+	if len(lines) != 3 {
+		t.Fatalf("audit log has %d lines, want 3", len(lines))
+	}
+
+	count, head, err := VerifyAuditLog(path, "")
+	if err != nil {
+		t.Fatalf("VerifyAuditLog() error = %v", err)
+	}
+	if count != 3 || head == "" {
+		t.Fatalf("VerifyAuditLog() = %d, %q, want 3 entries and a head", count, head)
+	}
+
+	// A new log continues the chain of the existing one.
+	auditLog := NewAuditLog(path, true)
+	err = auditLog.track(context.Background(), &auditChange{operation: auditOperationRevoke}, func() error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count, newHead, err := VerifyAuditLog(path, head)
+	if err != nil {
+		t.Fatalf("VerifyAuditLog() after reopening error = %v", err)
+	}
+	if count != 4 || newHead == head {
+		t.Fatalf("VerifyAuditLog() after reopening = %d, %q, want 4 entries and a new head", count, newHead)
+	}
+}
+
+func TestVerifyAuditLogTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(lines []string) []string
+		err    string
+	}{
+		{
+			name: "edited entry",
+			tamper: func(lines []string) []string {
+				lines[1] = strings.Replace(lines[1], `"result":"failure"`, `"result":"success"`, 1)
+				return lines
+			},
+			err: "line 2: entry 2 was modified",
+		},
+		{
+			name: "reordered entries",
+			tamper: func(lines []string) []string {
+				lines[1], lines[2] = lines[2], lines[1]
+				return lines
+			},
+			err: "line 2: expected entry 2, found entry 3",
+		},
+		{
+			name: "removed entry",
+			tamper: func(lines []string) []string {
+				return append(lines[:1:1], lines[2:]...)
+			},
+			err: "line 2: expected entry 2, found entry 3",
+		},
+		{
+			name: "removed first entry",
+			tamper: func(lines []string) []string {
+				return lines[1:]
+			},
+			err: "line 1: expected entry 1, found entry 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, lines := writeAuditLog(t, 4)
+			rewriteAuditLog(t, path, tt.tamper(lines))
+
+			_, _, err := VerifyAuditLog(path, "")
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("VerifyAuditLog() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestVerifyAuditLogTruncation(t *testing.T) {
+	path, lines := writeAuditLog(t, 4)
+
+	_, head, err := VerifyAuditLog(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rewriteAuditLog(t, path, lines[:2])
+
+	// The chain of the remaining entries is intact.
+	count, _, err := VerifyAuditLog(path, "")
+	if err != nil || count != 2 {
+		t.Fatalf("VerifyAuditLog() without head = %d, %v, want 2 entries", count, err)
+	}
+
+	if _, _, err := VerifyAuditLog(path, head); err == nil {
+		t.Fatal("VerifyAuditLog() with head of the truncated entries succeeded, want an error")
+	}
+}
+
+func TestAuditLogSharedByProcesses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	auditLogs := []*AuditLog{NewAuditLog(path, false), NewAuditLog(path, false)}
+
+	const appends = 20
+	var wg sync.WaitGroup
+	for _, auditLog := range auditLogs {
+		wg.Add(1)
+		go func(auditLog *AuditLog) {
+			defer wg.Done()
+
+			for i := 0; i < appends; i++ {
+				change := &auditChange{
+					operation: auditOperationGrant,
+					action:    "add_user_role",
+					principal: "user:42",
+					target:    "role:member:member",
+					// Entries larger than a read chunk make the last entry span several reads.
+					before: map[string]string{"padding": strings.Repeat("x", 70*1024)},
+				}
+
+				if err := auditLog.track(context.Background(), change, func() error { return nil }); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(auditLog)
+	}
+	wg.Wait()
+
+	count, _, err := VerifyAuditLog(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != appends*len(auditLogs) {
+		t.Errorf("audit log has %d entries, want %d", count, appends*len(auditLogs))
+	}
+}
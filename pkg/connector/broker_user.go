@@ -0,0 +1,333 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// brokerUserConnect is the entitlement of shovels and federation upstreams authenticating as a broker user.
+const brokerUserConnect = "connect"
+
+type brokerUserResourceType struct {
+	resourceType    *v2.ResourceType
+	definitions     *brokerDefinitions
+	connectionState *connectionState
+	protected       *protectedPrincipals
+	auditLog        *AuditLog
+	defaultLimits   cloudamqp.Limits
+}
+
+func (b *brokerUserResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return b.resourceType
+}
+
+// brokerUserResource creates a new connector resource for a user of the broker running on a CloudAMQP Instance.
+// Users synced from a definitions file are marked read-only in the profile. When connections of the broker
+// were sampled, the profile also tells when the user was last seen connected.
+// The SDK has no last-login field on the user trait yet, so the activity is kept in the profile only.
+// Limits of the user are kept in the profile when the broker supports them.
+func brokerUserResource(
+	instanceID int,
+	user *cloudamqp.BrokerUser,
+	readOnly bool,
+	protected bool,
+	activity *brokerUserActivity,
+	limits *brokerLimits,
+) (*v2.Resource, error) {
+	profile := map[string]interface{}{
+		"username":  user.Name,
+		"tags":      strings.Join(user.Tags, ","),
+		"read_only": readOnly,
+		"protected": protected,
+	}
+
+	if limits != nil {
+		limitsProfile(profile, limits.users[user.Name])
+	}
+
+	resourceOptions := []rs.ResourceOption{rs.WithParentResourceID(instanceResourceID(instanceID))}
+	if protected {
+		resourceOptions = append(resourceOptions, rs.WithDescription(protectedDescription))
+	}
+
+	if activity != nil {
+		profile["connection_count"] = activity.connections
+		profile["connections_observed_since"] = activity.observedSince.Format(time.RFC3339)
+		if !activity.lastSeen.IsZero() {
+			profile["last_seen_connected"] = activity.lastSeen.Format(time.RFC3339)
+		}
+	}
+
+	resource, err := rs.NewUserResource(
+		user.Name,
+		resourceTypeBrokerUser,
+		instanceScopedID(instanceID, user.Name),
+		[]rs.UserTraitOption{
+			rs.WithUserProfile(profile),
+			rs.WithStatus(v2.UserTrait_Status_STATUS_ENABLED),
+			rs.WithAccountType(v2.UserTrait_ACCOUNT_TYPE_SERVICE),
+		},
+		resourceOptions...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+func (b *brokerUserResourceType) List(ctx context.Context, parentID *v2.ResourceId, pt *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentID == nil || parentID.ResourceType != resourceTypeInstance.Id {
+		return nil, "", nil, nil
+	}
+
+	instanceID, err := parseInstanceID(parentID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	definitions, err := b.definitions.Get(ctx, instanceID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	activity := b.sampleConnections(ctx, instanceID, definitions.Users)
+
+	limits, err := b.definitions.Limits(ctx, instanceID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	rv := make([]*v2.Resource, 0, len(definitions.Users))
+	for _, user := range definitions.Users {
+		userCopy := user
+
+		protected, err := b.protected.IsBrokerUser(ctx, instanceID, user.Name)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		ur, err := brokerUserResource(instanceID, &userCopy, b.definitions.ReadOnly(instanceID), protected, activity[user.Name], limits)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rv = append(rv, ur)
+	}
+
+	return rv, "", nil, nil
+}
+
+// Entitlements returns the unlimited entitlement of the broker user, and the connect entitlement granted to shovels and
+// federation upstreams authenticating as the broker user.
+func (b *brokerUserResourceType) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	return []*v2.Entitlement{
+		unlimitedEntitlementFor(resource, resourceTypeBrokerUser, "broker user"),
+		ent.NewPermissionEntitlement(
+			resource,
+			brokerUserConnect,
+			ent.WithGrantableTo(resourceTypeShovel, resourceTypeFederationUpstream),
+			ent.WithDisplayName(fmt.Sprintf("%s broker user connect", resource.DisplayName)),
+			ent.WithDescription(fmt.Sprintf("Connects to the broker as broker user %s", resource.DisplayName)),
+		),
+	}, "", nil, nil
+}
+
+func (b *brokerUserResourceType) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	instanceID, parts, err := parseInstanceScopedID(resource.Id.Resource, 1)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	limits, err := b.definitions.Limits(ctx, instanceID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	if limits == nil {
+		return nil, "", nil, nil
+	}
+
+	return unlimitedGrant(resource, limits.users[parts[0]]), "", nil, nil
+}
+
+// Grant removes every limit of the broker user.
+func (b *brokerUserResourceType) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) (annotations.Annotations, error) {
+	if entitlement.Slug == brokerUserConnect {
+		return nil, fmt.Errorf("cloudamqp-connector: shovels and federation upstreams connect as broker users through their uris, which grants can not change")
+	}
+
+	err := b.updateLimits(ctx, auditOperationGrant, principal.Id, entitlement, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// Revoke sets the default limits back on the broker user.
+func (b *brokerUserResourceType) Revoke(ctx context.Context, g *v2.Grant) (annotations.Annotations, error) {
+	if g.Entitlement.Slug == brokerUserConnect {
+		return nil, fmt.Errorf("cloudamqp-connector: shovels and federation upstreams connect as broker users through their uris, which grants can not change")
+	}
+
+	if len(b.defaultLimits) == 0 {
+		return nil, fmt.Errorf("cloudamqp-connector: no default broker user limits are configured")
+	}
+
+	err := b.updateLimits(ctx, auditOperationRevoke, g.Principal.Id, g.Entitlement, b.defaultLimits)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// updateLimits replaces the limits of the broker user with provided ones.
+func (b *brokerUserResourceType) updateLimits(
+	ctx context.Context,
+	operation string,
+	principalID *v2.ResourceId,
+	entitlement *v2.Entitlement,
+	limits cloudamqp.Limits,
+) error {
+	err := checkUnlimitedPrincipal(principalID, entitlement)
+	if err != nil {
+		return err
+	}
+
+	instanceID, parts, err := parseInstanceScopedID(principalID.Resource, 1)
+	if err != nil {
+		return err
+	}
+	username := parts[0]
+
+	if b.definitions.ReadOnly(instanceID) {
+		return fmt.Errorf("cloudamqp-connector: instance %d is synced from a definitions file and can not be changed", instanceID)
+	}
+
+	err = b.protected.CheckBrokerUser(ctx, instanceID, username)
+	if err != nil {
+		return err
+	}
+
+	brokerClient, err := broker(ctx, b.definitions.accounts, instanceID)
+	if err != nil {
+		return err
+	}
+
+	current, err := b.definitions.fetchLimits(ctx, instanceID)
+	if err != nil {
+		return err
+	}
+
+	if current == nil {
+		return fmt.Errorf("cloudamqp-connector: broker of instance %d does not support user limits", instanceID)
+	}
+
+	change := &auditChange{
+		operation: operation,
+		action:    "update_user_limits",
+		principal: auditPrincipal(principalID),
+		target:    entitlement.Id,
+		before:    current.users[username],
+		after:     limits,
+	}
+
+	setter := &limitsSetter{
+		set: func(ctx context.Context, name string, value int64) error {
+			return brokerClient.SetUserLimit(ctx, username, name, value)
+		},
+		clear: func(ctx context.Context, name string) error {
+			return brokerClient.ClearUserLimit(ctx, username, name)
+		},
+	}
+
+	err = b.auditLog.track(ctx, change, func() error {
+		return setter.applyLimits(ctx, current.users[username], limits)
+	})
+	b.definitions.InvalidateLimits(instanceID)
+	if err != nil {
+		return fmt.Errorf("cloudamqp-connector: failed to update limits of broker user %s: %w", username, err)
+	}
+
+	return nil
+}
+
+// sampleConnections records which users of the broker are currently connected. Sampling is best effort,
+// brokers synced from a definitions file or failing to list connections are synced without activity.
+func (b *brokerUserResourceType) sampleConnections(ctx context.Context, instanceID int, users []cloudamqp.BrokerUser) map[string]*brokerUserActivity {
+	l := ctxzap.Extract(ctx)
+
+	if b.definitions.ReadOnly(instanceID) || b.definitions.Failure(instanceID) != nil {
+		return nil
+	}
+
+	ctx, cancel := b.definitions.withInstanceTimeout(ctx)
+	defer cancel()
+
+	brokerClient, err := broker(ctx, b.definitions.accounts, instanceID)
+	if err != nil {
+		l.Warn("cloudamqp-connector: failed to sample broker connections", zap.Int("instance_id", instanceID), zap.Error(err))
+		return nil
+	}
+
+	connections, err := brokerClient.GetConnections(ctx)
+	if err != nil {
+		l.Warn("cloudamqp-connector: failed to sample broker connections", zap.Int("instance_id", instanceID), zap.Error(err))
+		return nil
+	}
+
+	connectionCounts := make(map[string]int)
+	for _, connection := range connections {
+		connectionCounts[connection.User]++
+	}
+
+	usernames := make([]string, 0, len(users))
+	for _, user := range users {
+		usernames = append(usernames, user.Name)
+	}
+
+	activity, err := b.connectionState.Record(instanceID, usernames, connectionCounts, time.Now().UTC())
+	if err != nil {
+		l.Warn("cloudamqp-connector: failed to record broker connections", zap.Int("instance_id", instanceID), zap.Error(err))
+		return nil
+	}
+
+	return activity
+}
+
+func brokerUserResourceID(instanceID int, username string) *v2.ResourceId {
+	return &v2.ResourceId{
+		ResourceType: resourceTypeBrokerUser.Id,
+		Resource:     instanceScopedID(instanceID, username),
+	}
+}
+
+func brokerUserBuilder(
+	definitions *brokerDefinitions,
+	connectionState *connectionState,
+	protected *protectedPrincipals,
+	auditLog *AuditLog,
+	defaultLimits cloudamqp.Limits,
+) *brokerUserResourceType {
+	return &brokerUserResourceType{
+		resourceType:    resourceTypeBrokerUser,
+		definitions:     definitions,
+		connectionState: connectionState,
+		protected:       protected,
+		auditLog:        auditLog,
+		defaultLimits:   defaultLimits,
+	}
+}
@@ -0,0 +1,311 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Capabilities an API key can have.
+const (
+	CapabilityReadTeam      = "read_team"
+	CapabilityWriteTeam     = "write_team"
+	CapabilityReadInstances = "read_instances"
+	CapabilityInstanceAPI   = "instance_api"
+	CapabilityManagementAPI = "management_api"
+)
+
+// Outcomes of probing a capability.
+const (
+	CapabilityAvailable   = "available"
+	CapabilityUnavailable = "unavailable"
+	CapabilityUnknown     = "unknown"
+)
+
+var capabilityDescriptions = map[string]string{
+	CapabilityReadTeam:      "Read team users and roles",
+	CapabilityWriteTeam:     "Invite and remove users, change roles",
+	CapabilityReadInstances: "Read instances and VPCs",
+	CapabilityInstanceAPI:   "Reach the instance API (firewall, alarms, integrations, plugins)",
+	CapabilityManagementAPI: "Reach the management API of the brokers (users, vhosts, limits)",
+}
+
+var capabilityOrder = []string{
+	CapabilityReadTeam,
+	CapabilityWriteTeam,
+	CapabilityReadInstances,
+	CapabilityInstanceAPI,
+	CapabilityManagementAPI,
+}
+
+// Capability is the outcome of probing whether an API key can do something.
+type Capability struct {
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// AccountCapabilities are the capabilities of the API key of a team account.
+type AccountCapabilities struct {
+	Account      string                `json:"account,omitempty"`
+	Capabilities map[string]Capability `json:"capabilities"`
+}
+
+// CapabilityReport lists the capabilities of the API keys of every account synced by the connector.
+type CapabilityReport struct {
+	Accounts []AccountCapabilities `json:"accounts"`
+}
+
+// Can tells whether the API keys of every account have provided capability.
+func (r *CapabilityReport) Can(capability string) bool {
+	for _, acc := range r.Accounts {
+		if acc.Capabilities[capability].Status != CapabilityAvailable {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Lacks tells whether the API key of any account is known not to have provided capability. Capabilities that could
+// not be probed are not lacking.
+func (r *CapabilityReport) Lacks(capability string) bool {
+	for _, acc := range r.Accounts {
+		if acc.Capabilities[capability].Status == CapabilityUnavailable {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Write prints a human-readable report.
+func (r *CapabilityReport) Write(w io.Writer) error {
+	for _, acc := range r.Accounts {
+		if acc.Account != "" {
+			if _, err := fmt.Fprintf(w, "Account %s:\n", acc.Account); err != nil {
+				return err
+			}
+		}
+
+		for _, name := range capabilityOrder {
+			capability := acc.Capabilities[name]
+
+			line := fmt.Sprintf("  %-11s %-15s %s", capability.Status, name, capabilityDescriptions[name])
+			if capability.Detail != "" {
+				line += fmt.Sprintf(" (%s)", capability.Detail)
+			}
+
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// annotation returns the report as a struct attached to the response of Validate. ConductorOne does not read this
+// annotation, it is only informational for whoever inspects the raw response, while missing capabilities are logged.
+func (r *CapabilityReport) annotation() (*structpb.Struct, error) {
+	accounts := make([]interface{}, 0, len(r.Accounts))
+	for _, acc := range r.Accounts {
+		capabilities := make(map[string]interface{}, len(acc.Capabilities))
+		for name, capability := range acc.Capabilities {
+			capabilities[name] = map[string]interface{}{
+				"status": capability.Status,
+				"detail": capability.Detail,
+			}
+		}
+
+		accounts = append(accounts, map[string]interface{}{
+			"account":      acc.Account,
+			"capabilities": capabilities,
+		})
+	}
+
+	return structpb.NewStruct(map[string]interface{}{
+		"cloudamqp_capabilities": accounts,
+	})
+}
+
+// ProbeCapabilities checks which capabilities the API key of every account has. Reading the team is required, so
+// failing to do it is returned as an error explaining why, while other capabilities are only reported.
+func (pd *CloudAMQP) ProbeCapabilities(ctx context.Context) (*CapabilityReport, error) {
+	report := &CapabilityReport{}
+	for _, acc := range pd.accounts.list {
+		capabilities := make(map[string]Capability, len(capabilityOrder))
+
+		_, err := acc.client.GetUsers(ctx)
+		if err != nil {
+			return nil, validateError(pd.accounts, acc, "read the team", err)
+		}
+		capabilities[CapabilityReadTeam] = Capability{Status: CapabilityAvailable}
+
+		// The API has no read-only way to tell whether a key may change the team, so it is declared rather than probed.
+		if pd.teamWriteAccess {
+			capabilities[CapabilityWriteTeam] = Capability{Status: CapabilityAvailable, Detail: "declared with --team-write-access"}
+		} else {
+			capabilities[CapabilityWriteTeam] = Capability{Status: CapabilityUnknown, Detail: "not probed, declare it with --team-write-access"}
+		}
+
+		instances, err := acc.client.GetInstances(ctx)
+		if err != nil {
+			capabilities[CapabilityReadInstances] = probedCapability(err)
+			capabilities[CapabilityInstanceAPI] = Capability{Status: CapabilityUnknown, Detail: "instances can not be read"}
+			capabilities[CapabilityManagementAPI] = Capability{Status: CapabilityUnknown, Detail: "instances can not be read"}
+		} else {
+			capabilities[CapabilityReadInstances] = Capability{Status: CapabilityAvailable}
+			if err := pd.accounts.Register(acc, instances); err != nil {
+				return nil, err
+			}
+
+			probed, brokerProbed := -1, -1
+			for idx := range instances {
+				reason, err := pd.accounts.Excludes(ctx, acc, &instances[idx])
+				if err != nil {
+					return nil, err
+				}
+
+				if reason != "" {
+					continue
+				}
+
+				if probed < 0 {
+					probed = idx
+				}
+
+				if !pd.definitions.ReadOnly(instances[idx].Id) {
+					brokerProbed = idx
+					break
+				}
+			}
+
+			if probed < 0 {
+				capabilities[CapabilityInstanceAPI] = Capability{Status: CapabilityUnknown, Detail: "no synced instances"}
+			} else {
+				capabilities[CapabilityInstanceAPI] = probedCapability(pd.probeInstanceAPI(ctx, instances[probed].Id))
+			}
+
+			if brokerProbed < 0 {
+				capabilities[CapabilityManagementAPI] = Capability{Status: CapabilityUnknown, Detail: "no brokers synced through their management API"}
+			} else {
+				capability := probedCapability(pd.probeManagementAPI(ctx, instances[brokerProbed].Id))
+				if capability.Status == CapabilityUnavailable {
+					capability.Detail = "forbidden for the broker credentials"
+				}
+				capabilities[CapabilityManagementAPI] = capability
+			}
+		}
+
+		report.Accounts = append(report.Accounts, AccountCapabilities{
+			Account:      acc.name,
+			Capabilities: capabilities,
+		})
+	}
+
+	return report, nil
+}
+
+func (pd *CloudAMQP) probeInstanceAPI(ctx context.Context, instanceID int) error {
+	instanceClient, err := pd.accounts.InstanceClient(ctx, instanceID)
+	if err != nil {
+		return err
+	}
+
+	_, err = instanceClient.GetFirewallRules(ctx)
+
+	return err
+}
+
+// probeManagementAPI reads vhost limits of the broker running on provided instance, within the time allowed to fetch a
+// broker. The management API is reached with the credentials of the broker rather than the API key.
+func (pd *CloudAMQP) probeManagementAPI(ctx context.Context, instanceID int) error {
+	if pd.instanceTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, pd.instanceTimeout)
+		defer cancel()
+	}
+
+	brokerClient, err := broker(ctx, pd.accounts, instanceID)
+	if err != nil {
+		return err
+	}
+
+	_, err = brokerClient.GetVhostLimits(ctx)
+
+	return err
+}
+
+// capabilityReport returns the capabilities found by the last validation, probing them when no validation ran yet.
+// Failing to probe them is only logged, and leaves every capability unknown.
+func (pd *CloudAMQP) capabilityReport(ctx context.Context) *CapabilityReport {
+	pd.capabilitiesMtx.Lock()
+	report := pd.capabilities
+	pd.capabilitiesMtx.Unlock()
+	if report != nil {
+		return report
+	}
+
+	report, err := pd.ProbeCapabilities(ctx)
+	if err != nil {
+		ctxzap.Extract(ctx).Warn("cloudamqp-connector: failed to probe capabilities", zap.Error(err))
+		return &CapabilityReport{}
+	}
+
+	pd.capabilitiesMtx.Lock()
+	pd.capabilities = report
+	pd.capabilitiesMtx.Unlock()
+
+	return report
+}
+
+// probedCapability tells whether a capability is available from the error of a request probing it.
+func probedCapability(err error) Capability {
+	switch status.Code(err) {
+	case codes.OK:
+		return Capability{Status: CapabilityAvailable}
+	case codes.Code(http.StatusUnauthorized), codes.Code(http.StatusForbidden):
+		return Capability{Status: CapabilityUnavailable, Detail: "forbidden for the API key"}
+	default:
+		return unknownCapability(err)
+	}
+}
+
+func unknownCapability(err error) Capability {
+	return Capability{Status: CapabilityUnknown, Detail: err.Error()}
+}
+
+// validateError explains why a request made to validate the connector failed: invalid key, missing permission,
+// rate limiting, outage of the API or failure to reach it.
+func validateError(accounts *accounts, acc *account, action string, err error) error {
+	key := "Provided Access Token"
+	if accounts.named {
+		key = fmt.Sprintf("Provided Access Token of account %s", acc.name)
+	}
+
+	code := status.Code(err)
+	switch {
+	case code == codes.Code(http.StatusUnauthorized):
+		return status.Errorf(codes.Unauthenticated, "%s is invalid", key)
+	case code == codes.Code(http.StatusForbidden):
+		return status.Errorf(codes.PermissionDenied, "%s is not allowed to %s", key, action)
+	case code == codes.Code(http.StatusTooManyRequests):
+		return status.Errorf(codes.ResourceExhausted, "CloudAMQP API is rate limiting requests, try again later")
+	case code >= codes.Code(http.StatusInternalServerError):
+		return status.Errorf(codes.Unavailable, "CloudAMQP API is unavailable (HTTP %d), try again later", code)
+	case code >= codes.Code(http.StatusMultipleChoices):
+		return status.Errorf(codes.FailedPrecondition, "CloudAMQP API refused to %s (HTTP %d)", action, code)
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return status.Errorf(codes.DeadlineExceeded, "CloudAMQP API did not answer in time: %v", err)
+	default:
+		return status.Errorf(codes.Unavailable, "failed to reach CloudAMQP API: %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package connector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// brokerUserActivity is what connection sampling tells about a broker user.
+type brokerUserActivity struct {
+	connections   int
+	lastSeen      time.Time
+	observedSince time.Time
+}
+
+// connectionState remembers when broker users were last seen connected. When a path is configured the samples
+// are persisted across syncs, so that dormancy can be measured over longer periods than a single sync.
+type connectionState struct {
+	path string
+
+	mtx    sync.Mutex
+	loaded bool
+	// LastSeen maps broker user resource IDs to the last time the user had an open connection.
+	LastSeen map[string]time.Time `json:"last_seen"`
+	// ObservedSince maps instance IDs to the first time their connections were sampled.
+	ObservedSince map[string]time.Time `json:"observed_since"`
+}
+
+func newConnectionState(path string) *connectionState {
+	return &connectionState{
+		path:          path,
+		LastSeen:      make(map[string]time.Time),
+		ObservedSince: make(map[string]time.Time),
+	}
+}
+
+// Record stores a sample of connection counts per user of an instance and returns the activity of provided users.
+func (s *connectionState) Record(instanceID int, usernames []string, connections map[string]int, now time.Time) (map[string]*brokerUserActivity, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	instanceKey := strconv.Itoa(instanceID)
+	if _, ok := s.ObservedSince[instanceKey]; !ok {
+		s.ObservedSince[instanceKey] = now
+	}
+
+	rv := make(map[string]*brokerUserActivity, len(usernames))
+	for _, username := range usernames {
+		userKey := instanceScopedID(instanceID, username)
+		if connections[username] > 0 {
+			s.LastSeen[userKey] = now
+		}
+
+		rv[username] = &brokerUserActivity{
+			connections:   connections[username],
+			lastSeen:      s.LastSeen[userKey],
+			observedSince: s.ObservedSince[instanceKey],
+		}
+	}
+
+	if err := s.save(); err != nil {
+		return nil, err
+	}
+
+	return rv, nil
+}
+
+func (s *connectionState) load() error {
+	if s.loaded || s.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("cloudamqp-connector: failed to read connection state: %w", err)
+	}
+
+	if err == nil {
+		if err := json.Unmarshal(data, s); err != nil {
+			return fmt.Errorf("cloudamqp-connector: invalid connection state %s: %w", s.path, err)
+		}
+
+		if s.LastSeen == nil {
+			s.LastSeen = make(map[string]time.Time)
+		}
+		if s.ObservedSince == nil {
+			s.ObservedSince = make(map[string]time.Time)
+		}
+	}
+
+	s.loaded = true
+
+	return nil
+}
+
+// save writes the state next to its destination first, so that an interrupted write never corrupts it.
+func (s *connectionState) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("cloudamqp-connector: failed to write connection state: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("cloudamqp-connector: failed to write connection state: %w", err)
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("cloudamqp-connector: failed to write connection state: %w", err)
+	}
+
+	if err := os.Rename(tmpFile.Name(), s.path); err != nil {
+		return fmt.Errorf("cloudamqp-connector: failed to write connection state: %w", err)
+	}
+
+	return nil
+}
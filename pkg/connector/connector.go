@@ -2,6 +2,9 @@ package connector
 
 import (
 	"context"
+	"fmt"
+	"sync"
+	"time"
 
 	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
@@ -9,11 +12,17 @@ import (
 	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
 	"github.com/conductorone/baton-sdk/pkg/uhttp"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"go.uber.org/zap"
 )
 
 var (
+	resourceTypeAccount = &v2.ResourceType{
+		Id:          "account",
+		DisplayName: "Account",
+		Traits: []v2.ResourceType_Trait{
+			v2.ResourceType_TRAIT_APP,
+		},
+	}
 	resourceTypeUser = &v2.ResourceType{
 		Id:          "user",
 		DisplayName: "User",
@@ -29,45 +38,335 @@ var (
 			v2.ResourceType_TRAIT_ROLE,
 		},
 	}
+	resourceTypeVpc = &v2.ResourceType{
+		Id:          "vpc",
+		DisplayName: "VPC",
+		Traits: []v2.ResourceType_Trait{
+			v2.ResourceType_TRAIT_GROUP,
+		},
+	}
+	resourceTypeInstance = &v2.ResourceType{
+		Id:          "instance",
+		DisplayName: "Instance",
+		Traits: []v2.ResourceType_Trait{
+			v2.ResourceType_TRAIT_APP,
+		},
+	}
+	resourceTypeFirewallRule = &v2.ResourceType{
+		Id:          "firewall_rule",
+		DisplayName: "Firewall Rule",
+	}
+	resourceTypeAlarmRecipient = &v2.ResourceType{
+		Id:          "alarm_recipient",
+		DisplayName: "Alarm Recipient",
+		Traits: []v2.ResourceType_Trait{
+			v2.ResourceType_TRAIT_USER,
+		},
+		Annotations: annotationsForUserResourceType(),
+	}
+	resourceTypeIntegration = &v2.ResourceType{
+		Id:          "integration",
+		DisplayName: "Integration",
+		Traits: []v2.ResourceType_Trait{
+			v2.ResourceType_TRAIT_USER,
+		},
+		Annotations: annotationsForUserResourceType(),
+	}
+	resourceTypePlugin = &v2.ResourceType{
+		Id:          "plugin",
+		DisplayName: "Plugin",
+	}
+	resourceTypeVhost = &v2.ResourceType{
+		Id:          "vhost",
+		DisplayName: "Virtual Host",
+	}
+	resourceTypeBrokerUser = &v2.ResourceType{
+		Id:          "broker_user",
+		DisplayName: "Broker User",
+		Traits: []v2.ResourceType_Trait{
+			v2.ResourceType_TRAIT_USER,
+		},
+	}
+	resourceTypeQueue = &v2.ResourceType{
+		Id:          "queue",
+		DisplayName: "Queue",
+	}
+	resourceTypeExchange = &v2.ResourceType{
+		Id:          "exchange",
+		DisplayName: "Exchange",
+	}
+	resourceTypePolicy = &v2.ResourceType{
+		Id:          "policy",
+		DisplayName: "Policy",
+	}
+	resourceTypeOperatorPolicy = &v2.ResourceType{
+		Id:          "operator_policy",
+		DisplayName: "Operator Policy",
+	}
+	resourceTypeShovel = &v2.ResourceType{
+		Id:          "shovel",
+		DisplayName: "Shovel",
+		Traits: []v2.ResourceType_Trait{
+			v2.ResourceType_TRAIT_USER,
+		},
+	}
+	resourceTypeFederationUpstream = &v2.ResourceType{
+		Id:          "federation_upstream",
+		DisplayName: "Federation Upstream",
+		Traits: []v2.ResourceType_Trait{
+			v2.ResourceType_TRAIT_USER,
+		},
+	}
 )
 
 type CloudAMQP struct {
-	client *cloudamqp.Client
+	accounts             *accounts
+	accountTokens        []accountToken
+	definitions          *brokerDefinitions
+	instanceLists        *instanceLists
+	definitionFiles      map[int]string
+	connectionState      *connectionState
+	connectionStatePath  string
+	brokerEvents         bool
+	brokerEventsLog      string
+	eventSubscriberOpts  []cloudamqp.EventSubscriberOption
+	dryRun               bool
+	teamWriteAccess      bool
+	protectedUsers       []string
+	protectedBrokerUsers []ProtectedBrokerUser
+	protected            *protectedPrincipals
+	auditLogPath         string
+	auditLog             *AuditLog
+	capabilitiesMtx      sync.Mutex
+	capabilities         *CapabilityReport
+	defaultLimits        DefaultLimits
+	instanceConcurrency  int
+	instanceTimeout      time.Duration
+	instanceFilter       *InstanceFilter
+}
+
+type Option func(*CloudAMQP)
+
+type accountToken struct {
+	name  string
+	token string
+}
+
+// WithAccount syncs the team account of provided API key under an account resource with provided name. It can be
+// given once per account, in place of the API key passed to New.
+func WithAccount(name, token string) Option {
+	return func(c *CloudAMQP) {
+		c.accountTokens = append(c.accountTokens, accountToken{name: name, token: token})
+	}
+}
+
+// WithDefinitionsFiles syncs brokers of provided instances from definitions exports instead of their management API.
+func WithDefinitionsFiles(files map[int]string) Option {
+	return func(c *CloudAMQP) {
+		c.definitionFiles = files
+	}
+}
+
+// WithConnectionStateFile persists samples of broker connections in provided file across syncs.
+func WithConnectionStateFile(path string) Option {
+	return func(c *CloudAMQP) {
+		c.connectionStatePath = path
+	}
+}
+
+// WithBrokerEvents follows access events of the brokers for as long as the connector runs, refreshing the users,
+// vhosts and permissions they changed in cached broker data. When a log path is provided, events are also appended
+// to it as JSON lines. It must only be set on the process serving the connector, or events are followed twice.
+func WithBrokerEvents(logPath string, opts ...cloudamqp.EventSubscriberOption) Option {
+	return func(c *CloudAMQP) {
+		c.brokerEvents = true
+		c.brokerEventsLog = logPath
+		c.eventSubscriberOpts = opts
+	}
+}
+
+// WithTeamWriteAccess declares that the access tokens are allowed to change the team, which validation reports
+// without probing it.
+func WithTeamWriteAccess(teamWriteAccess bool) Option {
+	return func(c *CloudAMQP) {
+		c.teamWriteAccess = teamWriteAccess
+	}
+}
+
+// WithDryRun makes provisioning validate its inputs and resolve the current state, then log the requests it would
+// have sent instead of changing anything.
+func WithDryRun(dryRun bool) Option {
+	return func(c *CloudAMQP) {
+		c.dryRun = dryRun
+	}
+}
+
+// WithProtectedUsers refuses provisioning changes to provided team users, by email or ID, and to provided broker
+// users, on top of the default user of every broker.
+func WithProtectedUsers(users []string, brokerUsers []ProtectedBrokerUser) Option {
+	return func(c *CloudAMQP) {
+		c.protectedUsers = users
+		c.protectedBrokerUsers = brokerUsers
+	}
+}
+
+// WithAuditLog records every change provisioning makes in provided hash-chained audit log.
+func WithAuditLog(path string) Option {
+	return func(c *CloudAMQP) {
+		c.auditLogPath = path
+	}
+}
+
+// WithDefaultLimits sets provided limits back on broker users and vhosts when their unlimited entitlement is
+// revoked. Without default limits, the entitlement can only be granted.
+func WithDefaultLimits(limits DefaultLimits) Option {
+	return func(c *CloudAMQP) {
+		c.defaultLimits = limits
+	}
+}
+
+// WithInstanceConcurrency fetches brokers of up to provided number of instances at once, giving up on a broker after
+// provided timeout. Brokers failing to be fetched are synced without their data instead of failing the sync.
+func WithInstanceConcurrency(concurrency int, timeout time.Duration) Option {
+	return func(c *CloudAMQP) {
+		c.instanceConcurrency = concurrency
+		c.instanceTimeout = timeout
+	}
+}
+
+// WithInstanceFilter only syncs instances passing provided filter, along with their brokers and firewall rules.
+func WithInstanceFilter(filter *InstanceFilter) Option {
+	return func(c *CloudAMQP) {
+		c.instanceFilter = filter
+	}
 }
 
 func (pd *CloudAMQP) ResourceSyncers(ctx context.Context) []connectorbuilder.ResourceSyncer {
 	return []connectorbuilder.ResourceSyncer{
-		userBuilder(pd.client),
-		roleBuilder(pd.client),
+		accountBuilder(pd.accounts, pd.protected, pd.auditLog),
+		userBuilder(pd.accounts, pd.protected),
+		roleBuilder(pd.accounts, pd.protected, pd.auditLog),
+		vpcBuilder(pd.accounts),
+		instanceBuilder(pd.accounts, pd.definitions, pd.auditLog),
+		firewallRuleBuilder(pd.accounts, pd.instanceLists, pd.auditLog),
+		alarmRecipientBuilder(pd.accounts),
+		integrationBuilder(pd.accounts),
+		pluginBuilder(pd.accounts, pd.instanceLists, pd.auditLog),
+		vhostBuilder(pd.definitions, pd.auditLog, pd.defaultLimits.Vhost),
+		brokerUserBuilder(pd.definitions, pd.connectionState, pd.protected, pd.auditLog, pd.defaultLimits.User),
+		queueBuilder(pd.definitions),
+		exchangeBuilder(pd.definitions),
+		policyBuilder(pd.definitions),
+		operatorPolicyBuilder(pd.definitions),
+		shovelBuilder(pd.definitions),
+		federationUpstreamBuilder(pd.definitions),
 	}
 }
 
-// Metadata returns metadata about the connector.
-func (pd *CloudAMQP) Metadata(ctx context.Context) (*v2.ConnectorMetadata, error) {
-	return &v2.ConnectorMetadata{
-		DisplayName: "CloudAMQP",
-	}, nil
+// startSync drops data cached for the length of a sync.
+func (pd *CloudAMQP) startSync() {
+	pd.definitions.Reset()
+	pd.instanceLists.Reset()
+	pd.accounts.Reset()
 }
 
-// Validate hits the CloudAMQP API to validate that the configured credentials are valid and compatible.
+// Validate hits the CloudAMQP API to validate that the configured credentials are valid and compatible, and
+// reports which capabilities they have. The SDK calls it before every sync, so it also drops broker data cached by
+// the previous sync.
 func (pd *CloudAMQP) Validate(ctx context.Context) (annotations.Annotations, error) {
-	// should be able to list users
-	_, err := pd.client.GetUsers(ctx)
+	l := ctxzap.Extract(ctx)
+
+	pd.startSync()
+
+	report, err := pd.ProbeCapabilities(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, acc := range report.Accounts {
+		for name, capability := range acc.Capabilities {
+			if capability.Status == CapabilityUnavailable {
+				l.Warn(
+					"cloudamqp-connector: access token lacks capability",
+					zap.String("account", acc.Account),
+					zap.String("capability", name),
+					zap.String("status", capability.Status),
+					zap.String("detail", capability.Detail),
+				)
+			}
+		}
+	}
+
+	pd.capabilitiesMtx.Lock()
+	pd.capabilities = report
+	pd.capabilitiesMtx.Unlock()
+
+	annotation, err := report.annotation()
 	if err != nil {
-		return nil, status.Error(codes.Unauthenticated, "Provided Access Token is invalid")
+		return nil, err
 	}
 
-	return nil, nil
+	var annos annotations.Annotations
+	annos.Append(annotation)
+
+	return annos, nil
 }
 
 // New returns the CloudAMQP connector.
-func New(ctx context.Context, password string) (*CloudAMQP, error) {
+func New(ctx context.Context, password string, opts ...Option) (*CloudAMQP, error) {
 	httpClient, err := uhttp.NewClient(ctx, uhttp.WithLogger(true, ctxzap.Extract(ctx)))
 	if err != nil {
 		return nil, err
 	}
 
-	return &CloudAMQP{
-		client: cloudamqp.NewClient(httpClient, password),
-	}, nil
+	c := &CloudAMQP{
+		instanceConcurrency: DefaultInstanceConcurrency,
+		instanceTimeout:     DefaultInstanceTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	var list []*account
+	switch {
+	case len(c.accountTokens) > 0 && password != "":
+		return nil, fmt.Errorf("cloudamqp-connector: an access token and named accounts cannot be used together")
+	case len(c.accountTokens) > 0:
+		tokens := make(map[string]string, len(c.accountTokens))
+		for _, at := range c.accountTokens {
+			if other, ok := tokens[at.token]; ok {
+				return nil, fmt.Errorf("cloudamqp-connector: accounts %s and %s have the same token", other, at.name)
+			}
+			tokens[at.token] = at.name
+
+			list = append(list, &account{name: at.name, client: cloudamqp.NewClient(httpClient, at.token)})
+		}
+	default:
+		list = []*account{{client: cloudamqp.NewClient(httpClient, password)}}
+	}
+	c.accounts = newAccounts(list, c.instanceFilter)
+
+	if c.dryRun {
+		ctxzap.Extract(ctx).Info("cloudamqp-connector: dry run, changes are logged instead of made")
+		for _, acc := range c.accounts.list {
+			acc.client.EnableDryRun()
+		}
+	}
+
+	c.definitions = newBrokerDefinitions(c.accounts, c.definitionFiles, c.instanceConcurrency, c.instanceTimeout)
+	c.instanceLists = newInstanceLists(c.accounts)
+	c.connectionState = newConnectionState(c.connectionStatePath)
+	c.protected = newProtectedPrincipals(c.accounts, c.protectedUsers, c.protectedBrokerUsers)
+
+	if c.auditLogPath != "" {
+		c.auditLog = NewAuditLog(c.auditLogPath, c.dryRun)
+	}
+
+	if c.brokerEvents {
+		listener := newBrokerEventListener(c.accounts, c.definitions, c.brokerEventsLog, c.eventSubscriberOpts)
+		go listener.Run(ctx)
+	}
+
+	return c, nil
 }
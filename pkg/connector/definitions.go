@@ -0,0 +1,198 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// brokerDefinitions provides definitions of the brokers running on CloudAMQP instances. They are exported live
+// through the management API, or read from definitions files for brokers the connector can not reach.
+// Definitions are exported once per sync, so that a whole sync of a broker costs a single request, and brokers
+// failing to be fetched are synced without their data until the sync is over.
+type brokerDefinitions struct {
+	accounts        *accounts
+	files           map[int]string
+	concurrency     int
+	instanceTimeout time.Duration
+
+	mtx           sync.Mutex
+	cache         map[int]*cloudamqp.Definitions
+	limits        map[int]*brokerLimits
+	failures      map[int]error
+	limitFailures map[int]error
+}
+
+func newBrokerDefinitions(accounts *accounts, files map[int]string, concurrency int, instanceTimeout time.Duration) *brokerDefinitions {
+	return &brokerDefinitions{
+		accounts:        accounts,
+		files:           files,
+		concurrency:     concurrency,
+		instanceTimeout: instanceTimeout,
+		cache:           make(map[int]*cloudamqp.Definitions),
+		limits:          make(map[int]*brokerLimits),
+		failures:        make(map[int]error),
+		limitFailures:   make(map[int]error),
+	}
+}
+
+// Reset drops definitions, limits and failures of every broker, so that they are fetched again by the next sync.
+func (b *brokerDefinitions) Reset() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.cache = make(map[int]*cloudamqp.Definitions)
+	b.limits = make(map[int]*brokerLimits)
+	b.failures = make(map[int]error)
+	b.limitFailures = make(map[int]error)
+}
+
+// ReadOnly tells whether the broker of provided instance is synced from a definitions file.
+func (b *brokerDefinitions) ReadOnly(instanceID int) bool {
+	_, ok := b.files[instanceID]
+	return ok
+}
+
+// Get returns definitions of the broker running on provided instance. Brokers failing to be fetched have empty
+// definitions until the sync is over, so that the rest of the sync goes on without them.
+func (b *brokerDefinitions) Get(ctx context.Context, instanceID int) (*cloudamqp.Definitions, error) {
+	b.mtx.Lock()
+	cached, ok := b.cache[instanceID]
+	b.mtx.Unlock()
+
+	if ok {
+		return cached, nil
+	}
+
+	if b.Failure(instanceID) != nil {
+		return &cloudamqp.Definitions{}, nil
+	}
+
+	definitions, err := b.fetch(ctx, instanceID)
+	if err != nil {
+		b.fail(ctx, instanceID, err)
+		return &cloudamqp.Definitions{}, nil
+	}
+
+	b.mtx.Lock()
+	b.cache[instanceID] = definitions
+	b.mtx.Unlock()
+
+	return definitions, nil
+}
+
+// Failure returns why the broker of provided instance failed to be fetched during the sync, if it did.
+func (b *brokerDefinitions) Failure(instanceID int) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return b.failures[instanceID]
+}
+
+// fail records that the broker of provided instance failed to be fetched, so that it is not fetched again until
+// the sync is over.
+func (b *brokerDefinitions) fail(ctx context.Context, instanceID int, err error) {
+	ctxzap.Extract(ctx).Warn(
+		"cloudamqp-connector: failed to fetch broker, syncing instance without its broker data",
+		zap.Int("instance_id", instanceID),
+		zap.Error(err),
+	)
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.failures[instanceID] = err
+}
+
+// Cached tells whether definitions of provided instance are cached.
+func (b *brokerDefinitions) Cached(instanceID int) bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	_, ok := b.cache[instanceID]
+
+	return ok
+}
+
+// Patch changes the cached definitions of provided instance, if any. Definitions already handed out are left as they
+// are: patch is given a copy of them, and must replace the slices it changes rather than modify them.
+func (b *brokerDefinitions) Patch(instanceID int, patch func(definitions *cloudamqp.Definitions)) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	cached, ok := b.cache[instanceID]
+	if !ok {
+		return
+	}
+
+	patched := *cached
+	patch(&patched)
+	b.cache[instanceID] = &patched
+}
+
+// Invalidate drops cached definitions and limits of provided instance, so that they are exported again on next use.
+func (b *brokerDefinitions) Invalidate(instanceID int) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.ReadOnly(instanceID) {
+		return
+	}
+
+	delete(b.cache, instanceID)
+	delete(b.limits, instanceID)
+	delete(b.failures, instanceID)
+}
+
+func (b *brokerDefinitions) fetch(ctx context.Context, instanceID int) (*cloudamqp.Definitions, error) {
+	if path, ok := b.files[instanceID]; ok {
+		definitions, err := cloudamqp.LoadDefinitionsFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("cloudamqp-connector: failed to load definitions of instance %d: %w", instanceID, err)
+		}
+
+		return definitions, nil
+	}
+
+	ctx, cancel := b.withInstanceTimeout(ctx)
+	defer cancel()
+
+	brokerClient, err := broker(ctx, b.accounts, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	definitions, err := brokerClient.GetDefinitions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cloudamqp-connector: failed to export definitions of instance %d: %w", instanceID, err)
+	}
+
+	return definitions, nil
+}
+
+// readOnlyDescription marks descriptions of resources synced from a definitions file.
+func (b *brokerDefinitions) readOnlyDescription(instanceID int, description string) string {
+	if !b.ReadOnly(instanceID) {
+		return description
+	}
+
+	if description == "" {
+		return "Read-only, synced from a definitions export"
+	}
+
+	return fmt.Sprintf("%s (read-only, synced from a definitions export)", description)
+}
+
+// withInstanceTimeout bounds the time spent on the broker of a single instance.
+func (b *brokerDefinitions) withInstanceTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if b.instanceTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, b.instanceTimeout)
+}
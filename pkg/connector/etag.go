@@ -0,0 +1,44 @@
+package connector
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+)
+
+// grantsComputationVersion is mixed into grant ETags, and has to be bumped whenever the connector computes different
+// grants from the same API responses, so that grants of the previous sync are not reused.
+const grantsComputationVersion = "2"
+
+// grantsETag annotates grants of the single entitlement of a resource with the version of the API response they are
+// computed from and the configuration they depend on. When the ETag is the one the resource was annotated with by the
+// previous sync, the grants are unchanged and the syncer copies the previous ones instead, which is told by the returned
+// boolean. The response is fetched before the ETag is known, so a match saves computing grants, not API requests.
+func grantsETag(resource *v2.Resource, slug string, version string, config ...string) (annotations.Annotations, bool) {
+	if version == "" {
+		return nil, false
+	}
+
+	hash := sha256.Sum256([]byte(strings.Join(append([]string{grantsComputationVersion, version}, config...), "\n")))
+	version = hex.EncodeToString(hash[:])
+
+	entitlementID := ent.NewEntitlementID(resource, slug)
+
+	var annos annotations.Annotations
+
+	resourceAnnos := annotations.Annotations(resource.Annotations)
+	previous := &v2.ETag{}
+	ok, err := resourceAnnos.Pick(previous)
+	if err == nil && ok && previous.Value == version && previous.EntitlementId == entitlementID {
+		annos.Update(&v2.ETagMatch{EntitlementId: entitlementID})
+		return annos, true
+	}
+
+	annos.Update(&v2.ETag{Value: version, EntitlementId: entitlementID})
+
+	return annos, false
+}
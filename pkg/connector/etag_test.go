@@ -0,0 +1,46 @@
+package connector
+
+import (
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+)
+
+func TestGrantsETag(t *testing.T) {
+	resource := &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeRole.Id, Resource: "admin"}}
+
+	annos, unchanged := grantsETag(resource, roleMember, "v1", "named")
+	if unchanged {
+		t.Fatal("grants of a resource without ETag reported unchanged")
+	}
+
+	etag := &v2.ETag{}
+	if ok, err := annos.Pick(etag); err != nil || !ok {
+		t.Fatalf("no ETag annotation: %v", err)
+	}
+	var resourceAnnos annotations.Annotations
+	resourceAnnos.Update(etag)
+	resource.Annotations = resourceAnnos
+
+	tests := []struct {
+		name      string
+		version   string
+		config    []string
+		unchanged bool
+	}{
+		{name: "same response and config", version: "v1", config: []string{"named"}, unchanged: true},
+		{name: "changed response", version: "v2", config: []string{"named"}},
+		{name: "changed config", version: "v1", config: []string{"unnamed"}},
+		{name: "no version", version: "", config: []string{"named"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, unchanged := grantsETag(resource, roleMember, tt.version, tt.config...)
+			if unchanged != tt.unchanged {
+				t.Errorf("unchanged = %v, want %v", unchanged, tt.unchanged)
+			}
+		})
+	}
+}
@@ -0,0 +1,426 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Delays between attempts to subscribe again to events of a broker after the subscription is lost.
+const (
+	eventListenerMinBackoff = time.Second
+	eventListenerMaxBackoff = time.Minute
+)
+
+// Instances are listed again this often, so that events of new instances are followed and those of deleted or
+// excluded instances are not.
+const eventListenerRelistInterval = 5 * time.Minute
+
+// brokerEventRecord is a line of the broker events log.
+type brokerEventRecord struct {
+	InstanceID int `json:"instance_id"`
+	*cloudamqp.BrokerEvent
+	Resources []brokerEventResource `json:"resources"`
+}
+
+type brokerEventResource struct {
+	ResourceType string `json:"resource_type"`
+	Resource     string `json:"resource"`
+}
+
+// brokerEventListener follows access events of the brokers through their event exchange. Each event refreshes the
+// users, vhosts and permissions it touched in the cached definitions of its broker, so that the resources and grants
+// they make up are synced fresh, and is optionally appended to a local JSONL log.
+type brokerEventListener struct {
+	accounts       *accounts
+	definitions    *brokerDefinitions
+	logPath        string
+	subscriberOpts []cloudamqp.EventSubscriberOption
+	relistInterval time.Duration
+
+	logMtx sync.Mutex
+}
+
+func newBrokerEventListener(
+	accounts *accounts,
+	definitions *brokerDefinitions,
+	logPath string,
+	subscriberOpts []cloudamqp.EventSubscriberOption,
+) *brokerEventListener {
+	return &brokerEventListener{
+		accounts:       accounts,
+		definitions:    definitions,
+		logPath:        logPath,
+		subscriberOpts: subscriberOpts,
+		relistInterval: eventListenerRelistInterval,
+	}
+}
+
+// Run listens to events of every broker the connector can reach until the context is done. Instances are listed
+// again periodically, starting to listen to new ones and stopping for those gone.
+func (e *brokerEventListener) Run(ctx context.Context) {
+	l := ctxzap.Extract(ctx)
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	listening := make(map[int]context.CancelFunc)
+	for {
+		instanceIDs, err := e.instanceIDs(ctx)
+		if err != nil {
+			l.Warn("cloudamqp-connector: failed to list instances to follow broker events of", zap.Error(err))
+		} else {
+			for instanceID, cancel := range listening {
+				if !containsInt(instanceIDs, instanceID) {
+					cancel()
+					delete(listening, instanceID)
+				}
+			}
+
+			for _, instanceID := range instanceIDs {
+				if _, ok := listening[instanceID]; ok {
+					continue
+				}
+
+				listenCtx, cancel := context.WithCancel(ctx)
+				listening[instanceID] = cancel
+
+				wg.Add(1)
+				go func(instanceID int) {
+					defer wg.Done()
+					e.listen(listenCtx, instanceID)
+				}(instanceID)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(e.relistInterval):
+		}
+	}
+}
+
+// instanceIDs lists the instances whose broker events are followed: those synced from their management API.
+func (e *brokerEventListener) instanceIDs(ctx context.Context) ([]int, error) {
+	var rv []int
+	for _, acc := range e.accounts.list {
+		instances, err := acc.client.GetInstances(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cloudamqp-connector: failed to list instances of account %s: %w", acc.name, err)
+		}
+
+		if err := e.accounts.Register(acc, instances); err != nil {
+			return nil, err
+		}
+
+		for _, instance := range instances {
+			instanceCopy := instance
+			reason, err := e.accounts.Excludes(ctx, acc, &instanceCopy)
+			if err != nil {
+				return nil, err
+			}
+
+			if !e.definitions.ReadOnly(instance.Id) && reason == "" {
+				rv = append(rv, instance.Id)
+			}
+		}
+	}
+
+	return rv, nil
+}
+
+// listen keeps a subscription to events of provided instance, subscribing again with a growing delay when it is lost.
+func (e *brokerEventListener) listen(ctx context.Context, instanceID int) {
+	l := ctxzap.Extract(ctx)
+
+	backoff := eventListenerMinBackoff
+	for {
+		subscribedAt := time.Now()
+
+		err := e.subscribe(ctx, instanceID)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if errors.Is(err, cloudamqp.ErrUnsupportedByBroker) {
+			l.Info("cloudamqp-connector: not following broker events", zap.Int("instance_id", instanceID), zap.Error(err))
+			return
+		}
+
+		l.Warn(
+			"cloudamqp-connector: lost subscription to broker events",
+			zap.Int("instance_id", instanceID),
+			zap.Duration("retry_in", backoff),
+			zap.Error(err),
+		)
+
+		// the broker missed no events while the subscription was up for long, start over with the shortest delay
+		if time.Since(subscribedAt) > eventListenerMaxBackoff {
+			backoff = eventListenerMinBackoff
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > eventListenerMaxBackoff {
+			backoff = eventListenerMaxBackoff
+		}
+	}
+}
+
+func (e *brokerEventListener) subscribe(ctx context.Context, instanceID int) error {
+	subscriber, err := e.accounts.EventSubscriber(ctx, instanceID, e.subscriberOpts...)
+	if err != nil {
+		return fmt.Errorf("cloudamqp-connector: failed to get instance %d: %w", instanceID, err)
+	}
+
+	// events published before the subscription is up are missed, the definitions exported from now on include them
+	e.definitions.Invalidate(instanceID)
+
+	return subscriber.Subscribe(ctx, cloudamqp.AccessEvents, func(ctx context.Context, event *cloudamqp.BrokerEvent) error {
+		return e.handle(ctx, instanceID, event)
+	})
+}
+
+func (e *brokerEventListener) handle(ctx context.Context, instanceID int, event *cloudamqp.BrokerEvent) error {
+	l := ctxzap.Extract(ctx)
+
+	resources := brokerEventResources(instanceID, event)
+	e.refresh(ctx, instanceID, event)
+
+	resourceIDs := make([]string, 0, len(resources))
+	for _, resource := range resources {
+		resourceIDs = append(resourceIDs, fmt.Sprintf("%s/%s", resource.ResourceType, resource.Resource))
+	}
+
+	l.Info(
+		"cloudamqp-connector: broker access changed",
+		zap.Int("instance_id", instanceID),
+		zap.String("event", event.Type),
+		zap.Strings("resources", resourceIDs),
+	)
+
+	err := e.appendLog(&brokerEventRecord{
+		InstanceID:  instanceID,
+		BrokerEvent: event,
+		Resources:   resources,
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// refresh fetches the user, vhost or permissions changed by provided event and patches them into the cached
+// definitions of the broker. When they can not be fetched, the definitions are dropped to be exported again instead.
+func (e *brokerEventListener) refresh(ctx context.Context, instanceID int, event *cloudamqp.BrokerEvent) {
+	// definitions not cached yet are exported with the change
+	if !e.definitions.Cached(instanceID) {
+		return
+	}
+
+	patch, err := e.fetchChange(ctx, instanceID, event)
+	if err != nil {
+		ctxzap.Extract(ctx).Warn(
+			"cloudamqp-connector: failed to refresh broker access changed by event, exporting definitions again",
+			zap.Int("instance_id", instanceID),
+			zap.String("event", event.Type),
+			zap.Error(err),
+		)
+		e.definitions.Invalidate(instanceID)
+
+		return
+	}
+
+	if patch != nil {
+		e.definitions.Patch(instanceID, patch)
+	}
+}
+
+// fetchChange fetches what provided event changed and returns how to patch definitions with it.
+func (e *brokerEventListener) fetchChange(ctx context.Context, instanceID int, event *cloudamqp.BrokerEvent) (func(*cloudamqp.Definitions), error) {
+	brokerClient, err := broker(ctx, e.accounts, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	// only RabbitMQ brokers publish events
+	management, ok := brokerClient.(*cloudamqp.ManagementClient)
+	if !ok {
+		return nil, fmt.Errorf("cloudamqp-connector: broker of instance %d can not be refreshed selectively", instanceID)
+	}
+
+	switch event.Type {
+	case cloudamqp.EventUserCreated, cloudamqp.EventUserTagsSet:
+		user, err := management.GetUser(ctx, event.User())
+		if isNotFound(err) {
+			return removeBrokerUserPatch(event.User()), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return putBrokerUserPatch(user), nil
+
+	case cloudamqp.EventUserDeleted:
+		return removeBrokerUserPatch(event.User()), nil
+
+	case cloudamqp.EventPermissionCreated, cloudamqp.EventPermissionDeleted:
+		permissions, err := management.GetUserPermissions(ctx, event.User())
+		if err != nil && !isNotFound(err) {
+			return nil, err
+		}
+
+		return putPermissionsPatch(permissions, func(p *cloudamqp.Permission) bool { return p.User == event.User() }), nil
+
+	case cloudamqp.EventVhostCreated:
+		vhost, err := management.GetVhost(ctx, event.Vhost())
+		if isNotFound(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		permissions, err := management.GetVhostPermissions(ctx, event.Vhost())
+		if err != nil && !isNotFound(err) {
+			return nil, err
+		}
+
+		putPermissions := putPermissionsPatch(permissions, func(p *cloudamqp.Permission) bool { return p.Vhost == vhost.Name })
+
+		return func(definitions *cloudamqp.Definitions) {
+			vhosts := make([]cloudamqp.Vhost, 0, len(definitions.Vhosts)+1)
+			for _, v := range definitions.Vhosts {
+				if v.Name != vhost.Name {
+					vhosts = append(vhosts, v)
+				}
+			}
+			definitions.Vhosts = append(vhosts, *vhost)
+
+			putPermissions(definitions)
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// putBrokerUserPatch adds or replaces a user in definitions.
+func putBrokerUserPatch(user *cloudamqp.BrokerUser) func(*cloudamqp.Definitions) {
+	return func(definitions *cloudamqp.Definitions) {
+		users := make([]cloudamqp.BrokerUser, 0, len(definitions.Users)+1)
+		for _, u := range definitions.Users {
+			if u.Name != user.Name {
+				users = append(users, u)
+			}
+		}
+		definitions.Users = append(users, *user)
+	}
+}
+
+// removeBrokerUserPatch removes a user and its permissions from definitions, as the broker does when deleting it.
+func removeBrokerUserPatch(username string) func(*cloudamqp.Definitions) {
+	removePermissions := putPermissionsPatch(nil, func(p *cloudamqp.Permission) bool { return p.User == username })
+
+	return func(definitions *cloudamqp.Definitions) {
+		users := make([]cloudamqp.BrokerUser, 0, len(definitions.Users))
+		for _, u := range definitions.Users {
+			if u.Name != username {
+				users = append(users, u)
+			}
+		}
+		definitions.Users = users
+
+		removePermissions(definitions)
+	}
+}
+
+// putPermissionsPatch replaces the permissions of definitions selected by provided function with provided ones.
+func putPermissionsPatch(permissions []cloudamqp.Permission, selected func(*cloudamqp.Permission) bool) func(*cloudamqp.Definitions) {
+	return func(definitions *cloudamqp.Definitions) {
+		rv := make([]cloudamqp.Permission, 0, len(definitions.Permissions)+len(permissions))
+		for _, p := range definitions.Permissions {
+			pCopy := p
+			if !selected(&pCopy) {
+				rv = append(rv, p)
+			}
+		}
+		definitions.Permissions = append(rv, permissions...)
+	}
+}
+
+func isNotFound(err error) bool {
+	return status.Code(err) == codes.Code(http.StatusNotFound)
+}
+
+// appendLog writes a line per event to the broker events log, when one is configured.
+func (e *brokerEventListener) appendLog(record *brokerEventRecord) error {
+	if e.logPath == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	e.logMtx.Lock()
+	defer e.logMtx.Unlock()
+
+	file, err := os.OpenFile(e.logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("cloudamqp-connector: failed to open broker events log: %w", err)
+	}
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("cloudamqp-connector: failed to write broker events log: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("cloudamqp-connector: failed to write broker events log: %w", err)
+	}
+
+	return nil
+}
+
+// brokerEventResources returns the resources whose grants are changed by provided event.
+func brokerEventResources(instanceID int, event *cloudamqp.BrokerEvent) []brokerEventResource {
+	var ids []*v2.ResourceId
+	switch event.Type {
+	case cloudamqp.EventUserCreated, cloudamqp.EventUserDeleted, cloudamqp.EventUserTagsSet:
+		ids = append(ids, brokerUserResourceID(instanceID, event.User()))
+	case cloudamqp.EventPermissionCreated, cloudamqp.EventPermissionDeleted:
+		ids = append(ids, brokerUserResourceID(instanceID, event.User()), vhostResourceID(instanceID, event.Vhost()))
+	case cloudamqp.EventVhostCreated:
+		ids = append(ids, vhostResourceID(instanceID, event.Vhost()))
+	}
+
+	rv := make([]brokerEventResource, 0, len(ids))
+	for _, id := range ids {
+		rv = append(rv, brokerEventResource{
+			ResourceType: id.ResourceType,
+			Resource:     id.Resource,
+		})
+	}
+
+	return rv
+}
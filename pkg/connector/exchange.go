@@ -0,0 +1,116 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+)
+
+type exchangeResourceType struct {
+	resourceType *v2.ResourceType
+	definitions  *brokerDefinitions
+}
+
+func (e *exchangeResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return e.resourceType
+}
+
+// exchangeResource creates a new connector resource for an exchange in a virtual host of a CloudAMQP Instance.
+// The nameless default exchange is named the way RabbitMQ checks its permissions.
+func exchangeResource(instanceID int, exchange *cloudamqp.Exchange, description string) (*v2.Resource, error) {
+	name := exchange.Name
+	if name == "" {
+		name = defaultExchangePermissionName
+	}
+
+	resource, err := rs.NewResource(
+		name,
+		resourceTypeExchange,
+		instanceScopedID(instanceID, exchange.Vhost, name),
+		rs.WithParentResourceID(vhostResourceID(instanceID, exchange.Vhost)),
+		rs.WithDescription(description),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+func (e *exchangeResourceType) List(ctx context.Context, parentID *v2.ResourceId, pt *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentID == nil || parentID.ResourceType != resourceTypeVhost.Id {
+		return nil, "", nil, nil
+	}
+
+	instanceID, parts, err := parseInstanceScopedID(parentID.Resource, 1)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	definitions, err := e.definitions.Get(ctx, instanceID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	exchanges := definitions.VhostExchanges(parts[0])
+
+	rv := make([]*v2.Resource, 0, len(exchanges))
+	for _, exchange := range exchanges {
+		exchangeCopy := exchange
+
+		er, err := exchangeResource(instanceID, &exchangeCopy, e.definitions.readOnlyDescription(instanceID, fmt.Sprintf("%s exchange", exchange.Type)))
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rv = append(rv, er)
+	}
+
+	return rv, "", nil, nil
+}
+
+func (e *exchangeResourceType) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	return permissionEntitlements(resource, "exchange"), "", nil, nil
+}
+
+// Grants returns the effective access of broker users to the exchange, computed from their vhost permission patterns
+// and restricted by their topic permissions on the exchange.
+func (e *exchangeResourceType) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	instanceID, parts, err := parseInstanceScopedID(resource.Id.Resource, 2)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	definitions, err := e.definitions.Get(ctx, instanceID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	exchange := parts[1]
+	if exchange == defaultExchangePermissionName {
+		exchange = ""
+	}
+
+	grants := effectivePermissionGrants(
+		ctx,
+		instanceID,
+		resource,
+		parts[1],
+		definitions.VhostPermissions(parts[0]),
+		definitions.ExchangeTopicPermissions(parts[0], exchange),
+	)
+
+	return grants, "", nil, nil
+}
+
+func exchangeBuilder(definitions *brokerDefinitions) *exchangeResourceType {
+	return &exchangeResourceType{
+		resourceType: resourceTypeExchange,
+		definitions:  definitions,
+	}
+}
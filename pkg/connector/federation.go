@@ -0,0 +1,142 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+)
+
+type federationUpstreamResourceType struct {
+	resourceType *v2.ResourceType
+	definitions  *brokerDefinitions
+}
+
+func (f *federationUpstreamResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return f.resourceType
+}
+
+// federationUpstreamResource creates a new non-human identity for a federation upstream in a virtual host of a CloudAMQP Instance.
+// The profile records which brokers and users the upstream connects as, and whether it embeds passwords. Broker users
+// of synced brokers it authenticates as grant it their connect entitlement.
+func federationUpstreamResource(instanceID int, upstream *cloudamqp.FederationUpstream, endpoints []*amqpEndpoint) (*v2.Resource, error) {
+	upstreamsProfile, inlinePassword := endpointsProfile(endpoints)
+
+	profile := map[string]interface{}{
+		"name":            upstream.Name,
+		"vhost":           upstream.Vhost,
+		"exchange":        upstream.Exchange,
+		"queue":           upstream.Queue,
+		"upstreams":       upstreamsProfile,
+		"inline_password": inlinePassword,
+	}
+
+	resource, err := rs.NewUserResource(
+		upstream.Name,
+		resourceTypeFederationUpstream,
+		instanceScopedID(instanceID, upstream.Vhost, upstream.Name),
+		[]rs.UserTraitOption{
+			rs.WithUserProfile(profile),
+			rs.WithStatus(v2.UserTrait_Status_STATUS_ENABLED),
+			rs.WithAccountType(v2.UserTrait_ACCOUNT_TYPE_SERVICE),
+		},
+		rs.WithParentResourceID(vhostResourceID(instanceID, upstream.Vhost)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+func (f *federationUpstreamResourceType) List(ctx context.Context, parentID *v2.ResourceId, pt *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentID == nil || parentID.ResourceType != resourceTypeVhost.Id {
+		return nil, "", nil, nil
+	}
+
+	instanceID, parts, err := parseInstanceScopedID(parentID.Resource, 1)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	definitions, err := f.definitions.Get(ctx, instanceID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	upstreams, err := definitions.VhostFederationUpstreams(parts[0])
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("cloudamqp-connector: failed to list federation upstreams of vhost %s on instance %d: %w", parts[0], instanceID, err)
+	}
+
+	if len(upstreams) == 0 {
+		return nil, "", nil, nil
+	}
+
+	resolver, err := newAMQPEndpointResolver(ctx, f.definitions.accounts, instanceID)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("cloudamqp-connector: failed to get instance %d: %w", instanceID, err)
+	}
+
+	rv := make([]*v2.Resource, 0, len(upstreams))
+	for _, upstream := range upstreams {
+		upstreamCopy := upstream
+
+		ur, err := federationUpstreamResource(instanceID, &upstreamCopy, resolver.Resolve(ctx, upstream.URIs))
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rv = append(rv, ur)
+	}
+
+	return rv, "", nil, nil
+}
+
+func (f *federationUpstreamResourceType) Entitlements(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+// Grants returns the connect entitlements of the broker users the upstream authenticates as.
+func (f *federationUpstreamResourceType) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	instanceID, parts, err := parseInstanceScopedID(resource.Id.Resource, 2)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	definitions, err := f.definitions.Get(ctx, instanceID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	upstreams, err := definitions.VhostFederationUpstreams(parts[0])
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("cloudamqp-connector: failed to list federation upstreams of vhost %s on instance %d: %w", parts[0], instanceID, err)
+	}
+
+	for _, upstream := range upstreams {
+		if upstream.Name != parts[1] {
+			continue
+		}
+
+		resolver, err := newAMQPEndpointResolver(ctx, f.definitions.accounts, instanceID)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("cloudamqp-connector: failed to get instance %d: %w", instanceID, err)
+		}
+
+		return endpointGrants(resource, resolver.Resolve(ctx, upstream.URIs)), "", nil, nil
+	}
+
+	return nil, "", nil, nil
+}
+
+func federationUpstreamBuilder(definitions *brokerDefinitions) *federationUpstreamResourceType {
+	return &federationUpstreamResourceType{
+		resourceType: resourceTypeFederationUpstream,
+		definitions:  definitions,
+	}
+}
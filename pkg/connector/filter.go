@@ -0,0 +1,134 @@
+package connector
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
+)
+
+// InstanceCriteria match instances on the metadata listed by the customer API. Each set criterion must match, by any
+// of its values.
+type InstanceCriteria struct {
+	Names   []*regexp.Regexp
+	Tags    []string
+	Regions []string
+	Plans   []string
+	Engines []string
+}
+
+// InstanceFilter tells which instances are synced. Instances are synced when they match the include criteria and
+// none of the exclude criteria. Vhosts, broker users, firewall rules and other children of an instance are only
+// synced with it.
+type InstanceFilter struct {
+	Include InstanceCriteria
+	Exclude InstanceCriteria
+}
+
+// Excludes returns why provided instance is left out of syncs, or an empty string when it is synced.
+func (f *InstanceFilter) Excludes(instance *cloudamqp.Instance) string {
+	if f == nil {
+		return ""
+	}
+
+	if mismatches := f.Include.mismatches(instance); len(mismatches) > 0 {
+		return fmt.Sprintf("%s not included", strings.Join(mismatches, ", "))
+	}
+
+	if matches := f.Exclude.matches(instance); len(matches) > 0 {
+		return fmt.Sprintf("%s excluded", strings.Join(matches, ", "))
+	}
+
+	return ""
+}
+
+// needsDetails tells whether provided listed instance lacks data the filter needs, which its details have.
+func (f *InstanceFilter) needsDetails(instance *cloudamqp.Instance) bool {
+	if f == nil || instance.Backend != "" {
+		return false
+	}
+
+	return len(f.Include.Engines) > 0 || len(f.Exclude.Engines) > 0
+}
+
+// criterionMatch tells whether an instance matches a set criterion.
+type criterionMatch struct {
+	field   string
+	matched bool
+}
+
+// mismatches returns the set criteria the instance does not match.
+func (c *InstanceCriteria) mismatches(instance *cloudamqp.Instance) []string {
+	var rv []string
+	for _, m := range c.match(instance) {
+		if !m.matched {
+			rv = append(rv, m.field)
+		}
+	}
+
+	return rv
+}
+
+// matches returns the set criteria the instance matches.
+func (c *InstanceCriteria) matches(instance *cloudamqp.Instance) []string {
+	var rv []string
+	for _, m := range c.match(instance) {
+		if m.matched {
+			rv = append(rv, m.field)
+		}
+	}
+
+	return rv
+}
+
+// match tells for each set criterion whether the instance matches it.
+func (c *InstanceCriteria) match(instance *cloudamqp.Instance) []criterionMatch {
+	var rv []criterionMatch
+
+	if len(c.Names) > 0 {
+		matched := false
+		for _, name := range c.Names {
+			if name.MatchString(instance.Name) {
+				matched = true
+				break
+			}
+		}
+		rv = append(rv, criterionMatch{field: "name", matched: matched})
+	}
+
+	if len(c.Tags) > 0 {
+		matched := false
+		for _, tag := range instance.Tags {
+			if containsString(c.Tags, tag) {
+				matched = true
+				break
+			}
+		}
+		rv = append(rv, criterionMatch{field: "tag", matched: matched})
+	}
+
+	if len(c.Regions) > 0 {
+		rv = append(rv, criterionMatch{field: "region", matched: containsFold(c.Regions, instance.Region)})
+	}
+
+	if len(c.Plans) > 0 {
+		rv = append(rv, criterionMatch{field: "plan", matched: containsFold(c.Plans, instance.Plan)})
+	}
+
+	if len(c.Engines) > 0 {
+		rv = append(rv, criterionMatch{field: "engine", matched: containsFold(c.Engines, instance.Engine())})
+	}
+
+	return rv
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,287 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+var firewallServices = []string{
+	"AMQP", "AMQPS", "HTTPS", "MQTT", "MQTTS", "STOMP", "STOMPS", "STREAM", "STREAM_SSL",
+}
+
+type firewallRuleResourceType struct {
+	resourceType *v2.ResourceType
+	accounts     *accounts
+	lists        *instanceLists
+	auditLog     *AuditLog
+}
+
+func (f *firewallRuleResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return f.resourceType
+}
+
+// firewallRuleResource creates a new connector resource for a firewall rule of a CloudAMQP Instance.
+func firewallRuleResource(instanceID int, rule *cloudamqp.FirewallRule) (*v2.Resource, error) {
+	displayName := rule.Ip
+	if rule.Description != "" {
+		displayName = fmt.Sprintf("%s (%s)", rule.Ip, rule.Description)
+	}
+
+	resource, err := rs.NewResource(
+		displayName,
+		resourceTypeFirewallRule,
+		instanceScopedID(instanceID, rule.Ip),
+		rs.WithParentResourceID(instanceResourceID(instanceID)),
+		rs.WithDescription(rule.Description),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+func (f *firewallRuleResourceType) List(ctx context.Context, parentID *v2.ResourceId, pt *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentID == nil || parentID.ResourceType != resourceTypeInstance.Id {
+		return nil, "", nil, nil
+	}
+
+	instanceID, err := parseInstanceID(parentID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	rules, err := f.lists.FirewallRules(ctx, instanceID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	rv := make([]*v2.Resource, 0, len(rules))
+	for _, rule := range rules {
+		ruleCopy := rule
+
+		fr, err := firewallRuleResource(instanceID, &ruleCopy)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rv = append(rv, fr)
+	}
+
+	return rv, "", nil, nil
+}
+
+func (f *firewallRuleResourceType) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	rv := make([]*v2.Entitlement, 0, len(firewallServices))
+	for _, service := range firewallServices {
+		rv = append(rv, ent.NewPermissionEntitlement(
+			resource,
+			service,
+			ent.WithGrantableTo(resourceTypeInstance),
+			ent.WithDisplayName(fmt.Sprintf("%s %s access", resource.DisplayName, service)),
+			ent.WithDescription(fmt.Sprintf("%s access to the instance allowed by firewall rule %s", service, resource.DisplayName)),
+		))
+	}
+
+	return rv, "", nil, nil
+}
+
+// Grants returns the services the firewall rule allows, from the rules of its instance listed once per sync.
+func (f *firewallRuleResourceType) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	instanceID, parts, err := parseInstanceScopedID(resource.Id.Resource, 1)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	rules, err := f.lists.FirewallRules(ctx, instanceID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	var rv []*v2.Grant
+	for _, rule := range rules {
+		if rule.Ip != parts[0] {
+			continue
+		}
+
+		for _, service := range rule.Services {
+			rv = append(rv, grant.NewGrant(resource, service, instanceResourceID(instanceID)))
+		}
+	}
+
+	return rv, "", nil, nil
+}
+
+// Grant allows the service of the entitlement through the firewall rule, creating the rule if it does not exist yet.
+func (f *firewallRuleResourceType) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	instanceID, ip, service, err := f.parseFirewallEntitlement(ctx, principal.Id, entitlement)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := f.lists.fetchFirewallRules(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	before := append([]cloudamqp.FirewallRule(nil), rules...)
+
+	found := false
+	for i, rule := range rules {
+		if rule.Ip != ip {
+			continue
+		}
+
+		found = true
+		if containsString(rule.Services, service) {
+			l.Info(
+				"cloudamqp-connector: firewall rule already allows service",
+				zap.String("ip", ip),
+				zap.String("service", service),
+			)
+
+			return nil, nil
+		}
+
+		rules[i].Services = append(rules[i].Services, service)
+	}
+
+	if !found {
+		rules = append(rules, cloudamqp.FirewallRule{Ip: ip, Services: []string{service}})
+	}
+
+	change := &auditChange{
+		operation: auditOperationGrant,
+		action:    "update_firewall_rules",
+		principal: auditPrincipal(principal.Id),
+		target:    entitlement.Id,
+		before:    before,
+		after:     rules,
+	}
+
+	err = f.updateFirewallRules(ctx, change, instanceID, rules)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// Revoke disallows the service of the grant, removing the whole rule once it allows no services.
+func (f *firewallRuleResourceType) Revoke(ctx context.Context, g *v2.Grant) (annotations.Annotations, error) {
+	instanceID, ip, service, err := f.parseFirewallEntitlement(ctx, g.Principal.Id, g.Entitlement)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := f.lists.fetchFirewallRules(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	updatedRules := make([]cloudamqp.FirewallRule, 0, len(rules))
+	for _, rule := range rules {
+		if rule.Ip == ip {
+			rule.Services = removeString(rule.Services, service)
+			if len(rule.Services) == 0 {
+				continue
+			}
+		}
+
+		updatedRules = append(updatedRules, rule)
+	}
+
+	change := &auditChange{
+		operation: auditOperationRevoke,
+		action:    "update_firewall_rules",
+		principal: auditPrincipal(g.Principal.Id),
+		target:    g.Entitlement.Id,
+		before:    rules,
+		after:     updatedRules,
+	}
+
+	err = f.updateFirewallRules(ctx, change, instanceID, updatedRules)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// parseFirewallEntitlement validates that the principal is the instance owning the firewall rule
+// and returns the instance ID, the rule IP and the service of provided entitlement.
+func (f *firewallRuleResourceType) parseFirewallEntitlement(
+	ctx context.Context,
+	principalID *v2.ResourceId,
+	entitlement *v2.Entitlement,
+) (int, string, string, error) {
+	l := ctxzap.Extract(ctx)
+
+	if principalID.ResourceType != resourceTypeInstance.Id {
+		l.Warn(
+			"cloudamqp-connector: only instances can be granted firewall access",
+			zap.String("principal_id", principalID.String()),
+			zap.String("principal_type", principalID.ResourceType),
+		)
+
+		return 0, "", "", fmt.Errorf("cloudamqp-connector: only instances can be granted firewall access")
+	}
+
+	instanceID, parts, err := parseInstanceScopedID(entitlement.Resource.Id.Resource, 1)
+	if err != nil {
+		return 0, "", "", err
+	}
+
+	if principalID.Resource != instanceResourceID(instanceID).Resource {
+		return 0, "", "", fmt.Errorf("cloudamqp-connector: firewall rule %s does not belong to instance %s", parts[0], principalID.Resource)
+	}
+
+	if !containsString(firewallServices, entitlement.Slug) {
+		return 0, "", "", fmt.Errorf("cloudamqp-connector: unknown firewall service %s", entitlement.Slug)
+	}
+
+	return instanceID, parts[0], entitlement.Slug, nil
+}
+
+func (f *firewallRuleResourceType) updateFirewallRules(
+	ctx context.Context,
+	change *auditChange,
+	instanceID int,
+	rules []cloudamqp.FirewallRule,
+) error {
+	instanceClient, err := f.accounts.InstanceClient(ctx, instanceID)
+	if err != nil {
+		return fmt.Errorf("cloudamqp-connector: failed to get instance %d: %w", instanceID, err)
+	}
+
+	err = f.auditLog.track(ctx, change, func() error {
+		return instanceClient.UpdateFirewallRules(ctx, rules)
+	})
+	f.lists.InvalidateFirewallRules(instanceID)
+	if err != nil {
+		return fmt.Errorf("cloudamqp-connector: failed to update firewall rules of instance %d: %w", instanceID, err)
+	}
+
+	return nil
+}
+
+func firewallRuleBuilder(accounts *accounts, lists *instanceLists, auditLog *AuditLog) *firewallRuleResourceType {
+	return &firewallRuleResourceType{
+		resourceType: resourceTypeFirewallRule,
+		accounts:     accounts,
+		lists:        lists,
+		auditLog:     auditLog,
+	}
+}
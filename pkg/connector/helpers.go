@@ -1,6 +1,11 @@
 package connector
 
 import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
 	"golang.org/x/text/cases"
@@ -9,6 +14,8 @@ import (
 
 const ResourcesPageSize = 50
 
+const idSeparator = ":"
+
 func titleCase(s string) string {
 	titleCaser := cases.Title(language.English)
 
@@ -20,3 +27,106 @@ func annotationsForUserResourceType() annotations.Annotations {
 	annos.Update(&v2.SkipEntitlementsAndGrants{})
 	return annos
 }
+
+func toInterfaceSlice(values []string) []interface{} {
+	rv := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		rv = append(rv, v)
+	}
+
+	return rv
+}
+
+// idComponentEscaper escapes the separator in components of IDs, and the escape character itself so that escaped
+// components can be told apart. Components without either are kept as is.
+var idComponentEscaper = strings.NewReplacer("%", "%25", idSeparator, "%3A")
+
+// instanceScopedID builds the ID of a resource living on a CloudAMQP instance. Parts are escaped, as names of
+// vhosts, queues and other broker resources may contain the separator.
+func instanceScopedID(instanceID int, parts ...string) string {
+	components := make([]string, 0, len(parts)+1)
+	components = append(components, strconv.Itoa(instanceID))
+	for _, part := range parts {
+		components = append(components, idComponentEscaper.Replace(part))
+	}
+
+	return strings.Join(components, idSeparator)
+}
+
+// parseInstanceScopedID splits the ID of a resource living on a CloudAMQP instance
+// into the instance ID and exactly n remaining unescaped parts.
+func parseInstanceScopedID(id string, n int) (int, []string, error) {
+	components := strings.Split(id, idSeparator)
+	if len(components) != n+1 {
+		return 0, nil, fmt.Errorf("cloudamqp-connector: invalid instance scoped id %s", id)
+	}
+
+	instanceID, err := strconv.Atoi(components[0])
+	if err != nil {
+		return 0, nil, fmt.Errorf("cloudamqp-connector: invalid instance id in %s: %w", id, err)
+	}
+
+	parts := make([]string, 0, n)
+	for _, component := range components[1:] {
+		part, err := url.PathUnescape(component)
+		if err != nil {
+			return 0, nil, fmt.Errorf("cloudamqp-connector: invalid instance scoped id %s: %w", id, err)
+		}
+
+		parts = append(parts, part)
+	}
+
+	return instanceID, parts, nil
+}
+
+// parseInstanceID returns the instance ID of provided instance resource ID.
+func parseInstanceID(resourceID *v2.ResourceId) (int, error) {
+	if resourceID == nil || resourceID.ResourceType != resourceTypeInstance.Id {
+		return 0, fmt.Errorf("cloudamqp-connector: %v is not an instance", resourceID)
+	}
+
+	instanceID, err := strconv.Atoi(resourceID.Resource)
+	if err != nil {
+		return 0, fmt.Errorf("cloudamqp-connector: invalid instance id %s: %w", resourceID.Resource, err)
+	}
+
+	return instanceID, nil
+}
+
+func instanceResourceID(instanceID int) *v2.ResourceId {
+	return &v2.ResourceId{
+		ResourceType: resourceTypeInstance.Id,
+		Resource:     strconv.Itoa(instanceID),
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func removeString(values []string, value string) []string {
+	rv := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != value {
+			rv = append(rv, v)
+		}
+	}
+
+	return rv
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
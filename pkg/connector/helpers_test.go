@@ -0,0 +1,60 @@
+package connector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInstanceScopedID(t *testing.T) {
+	tests := []struct {
+		name  string
+		parts []string
+		id    string
+	}{
+		{name: "plain", parts: []string{"vhost", "queue"}, id: "42:vhost:queue"},
+		{name: "default vhost", parts: []string{"/", "queue"}, id: "42:/:queue"},
+		{name: "separator in vhost", parts: []string{"team:a", "queue"}, id: "42:team%3Aa:queue"},
+		{name: "separator in last part", parts: []string{"vhost", "orders:eu"}, id: "42:vhost:orders%3Aeu"},
+		{name: "escape character", parts: []string{"50%", "%3A"}, id: "42:50%25:%253A"},
+		{name: "empty part", parts: []string{"", "queue"}, id: "42::queue"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := instanceScopedID(42, tt.parts...)
+			if id != tt.id {
+				t.Fatalf("instanceScopedID() = %q, want %q", id, tt.id)
+			}
+
+			instanceID, parts, err := parseInstanceScopedID(id, len(tt.parts))
+			if err != nil {
+				t.Fatalf("parseInstanceScopedID() error = %v", err)
+			}
+
+			if instanceID != 42 || !reflect.DeepEqual(parts, tt.parts) {
+				t.Fatalf("parseInstanceScopedID() = %d, %q, want 42, %q", instanceID, parts, tt.parts)
+			}
+		})
+	}
+}
+
+func TestParseInstanceScopedIDErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		n    int
+	}{
+		{name: "missing part", id: "42:vhost", n: 2},
+		{name: "unescaped separator", id: "42:team:a:queue", n: 2},
+		{name: "invalid instance id", id: "abc:vhost", n: 1},
+		{name: "invalid escape", id: "42:50%", n: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := parseInstanceScopedID(tt.id, tt.n); err == nil {
+				t.Fatalf("parseInstanceScopedID(%q, %d) succeeded, want an error", tt.id, tt.n)
+			}
+		})
+	}
+}
@@ -0,0 +1,509 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+const (
+	alarmEntitlementPrefix = "alarm:"
+	integrationEntitlement = "integration"
+)
+
+var instanceChildResourceTypes = []*v2.ResourceType{
+	resourceTypeFirewallRule,
+	resourceTypeAlarmRecipient,
+	resourceTypeIntegration,
+	resourceTypePlugin,
+	resourceTypeVhost,
+	resourceTypeBrokerUser,
+}
+
+type instanceResourceType struct {
+	resourceType *v2.ResourceType
+	accounts     *accounts
+	definitions  *brokerDefinitions
+	auditLog     *AuditLog
+}
+
+func (i *instanceResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return i.resourceType
+}
+
+// instanceResource creates a new connector resource for a CloudAMQP Instance. When its broker failed to be fetched,
+// the error is kept in the profile, as the broker's users, vhosts and their children are then missing from the sync.
+func instanceResource(instance *cloudamqp.Instance, parentResourceID *v2.ResourceId, brokerErr error) (*v2.Resource, error) {
+	profile := map[string]interface{}{
+		"instance_id": instance.Id,
+		"name":        instance.Name,
+		"plan":        instance.Plan,
+		"region":      instance.Region,
+		"engine":      instance.Engine(),
+		"tags":        toInterfaceSlice(instance.Tags),
+	}
+
+	if brokerErr != nil {
+		profile["sync_error"] = brokerErr.Error()
+	}
+
+	resourceOptions := []rs.ResourceOption{rs.WithParentResourceID(parentResourceID)}
+	for _, childResourceType := range instanceChildResourceTypes {
+		resourceOptions = append(resourceOptions, rs.WithAnnotation(&v2.ChildResourceType{ResourceTypeId: childResourceType.Id}))
+	}
+
+	resource, err := rs.NewAppResource(
+		instance.Name,
+		resourceTypeInstance,
+		instance.Id,
+		[]rs.AppTraitOption{rs.WithAppProfile(profile)},
+		resourceOptions...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+// List returns instances placed in the parent VPC, or instances outside any VPC of the parent account. Instances of
+// an unnamed account outside any VPC have no parent. Brokers of the listed instances are fetched concurrently ahead
+// of their children, so that an unreachable broker only leaves its own data out of the sync. Instances left out by the
+// instance filter are not listed, and so are their children, which is logged once per sync as a summary.
+func (i *instanceResourceType) List(ctx context.Context, parentID *v2.ResourceId, pt *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	var (
+		acc   *account
+		vpcId int
+	)
+	if parentID != nil && parentID.ResourceType == resourceTypeVpc.Id {
+		var (
+			id  string
+			err error
+		)
+		acc, id, err = i.accounts.ParseScopedID(parentID.Resource)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		vpcId, err = strconv.Atoi(id)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("cloudamqp-connector: invalid vpc id %s: %w", parentID.Resource, err)
+		}
+	} else {
+		accounts, err := i.accounts.ForParent(parentID)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		if len(accounts) == 0 {
+			return nil, "", nil, nil
+		}
+		acc = accounts[0]
+	}
+
+	instances, err := acc.client.GetInstances(ctx)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("cloudamqp-connector: failed to list instances: %w", err)
+	}
+	if err := i.accounts.Register(acc, instances); err != nil {
+		return nil, "", nil, err
+	}
+
+	if err := i.accounts.LogExclusions(ctx); err != nil {
+		return nil, "", nil, err
+	}
+
+	var (
+		listed      []cloudamqp.Instance
+		instanceIDs []int
+	)
+	for _, instance := range instances {
+		if instance.VpcId != vpcId {
+			continue
+		}
+
+		instanceCopy := instance
+		reason, err := i.accounts.Excludes(ctx, acc, &instanceCopy)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		if reason != "" {
+			continue
+		}
+
+		listed = append(listed, instance)
+		instanceIDs = append(instanceIDs, instance.Id)
+	}
+
+	i.definitions.Prefetch(ctx, instanceIDs)
+
+	rv := make([]*v2.Resource, 0, len(listed))
+	for _, instance := range listed {
+		instanceCopy := instance
+
+		ir, err := instanceResource(&instanceCopy, parentID, i.definitions.Failure(instance.Id))
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rv = append(rv, ir)
+	}
+
+	return rv, "", nil, nil
+}
+
+// Entitlements returns the integration entitlement held by third-party integrations exporting data of the instance
+// and one entitlement per alarm of the instance, granted to the alarm's notification recipients.
+func (i *instanceResourceType) Entitlements(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	instanceID, err := parseInstanceID(resource.Id)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	alarms, err := i.alarms(ctx, instanceID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	rv := make([]*v2.Entitlement, 0, len(alarms)+1)
+	rv = append(rv, ent.NewPermissionEntitlement(
+		resource,
+		integrationEntitlement,
+		ent.WithGrantableTo(resourceTypeIntegration),
+		ent.WithDisplayName(fmt.Sprintf("%s data export", resource.DisplayName)),
+		ent.WithDescription(fmt.Sprintf("Logs or metrics of %s are shipped to the integration", resource.DisplayName)),
+	))
+
+	for _, alarm := range alarms {
+		alarmCopy := alarm
+
+		rv = append(rv, ent.NewAssignmentEntitlement(
+			resource,
+			alarmEntitlementSlug(alarm.Id),
+			ent.WithGrantableTo(resourceTypeAlarmRecipient),
+			ent.WithDisplayName(fmt.Sprintf("%s %s alarm", resource.DisplayName, titleCase(alarm.Type))),
+			ent.WithDescription(alarmDescription(&alarmCopy)),
+		))
+	}
+
+	return rv, "", nil, nil
+}
+
+func (i *instanceResourceType) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	instanceID, err := parseInstanceID(resource.Id)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	alarms, err := i.alarms(ctx, instanceID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	integrations, err := listIntegrations(ctx, i.accounts, instanceID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	var rv []*v2.Grant
+	for _, integration := range integrations {
+		rv = append(rv, grant.NewGrant(
+			resource,
+			integrationEntitlement,
+			&v2.ResourceId{
+				ResourceType: resourceTypeIntegration.Id,
+				Resource:     integrationID(instanceID, integration.Kind, integration.Id),
+			},
+		))
+	}
+
+	for _, alarm := range alarms {
+		for _, recipientID := range alarm.Recipients {
+			rv = append(rv, grant.NewGrant(
+				resource,
+				alarmEntitlementSlug(alarm.Id),
+				&v2.ResourceId{
+					ResourceType: resourceTypeAlarmRecipient.Id,
+					Resource:     instanceScopedID(instanceID, strconv.Itoa(recipientID)),
+				},
+			))
+		}
+	}
+
+	return rv, "", nil, nil
+}
+
+// Grant subscribes the recipient to the alarm of the entitlement. Integrations can only be revoked.
+func (i *instanceResourceType) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) (annotations.Annotations, error) {
+	if entitlement.Slug == integrationEntitlement {
+		return nil, fmt.Errorf("cloudamqp-connector: integrations can not be created through grants")
+	}
+
+	return i.subscribeToAlarm(ctx, principal, entitlement)
+}
+
+// Revoke unsubscribes the recipient from the alarm of the grant, or deletes the integration of the grant.
+func (i *instanceResourceType) Revoke(ctx context.Context, g *v2.Grant) (annotations.Annotations, error) {
+	if g.Entitlement.Slug == integrationEntitlement {
+		return i.deleteIntegration(ctx, g)
+	}
+
+	return i.unsubscribeFromAlarm(ctx, g)
+}
+
+func (i *instanceResourceType) subscribeToAlarm(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	instanceID, alarmID, recipientID, err := parseAlarmSubscription(ctx, principal.Id, entitlement)
+	if err != nil {
+		return nil, err
+	}
+
+	instanceClient, alarm, err := i.alarm(ctx, instanceID, alarmID)
+	if err != nil {
+		return nil, err
+	}
+
+	if containsInt(alarm.Recipients, recipientID) {
+		l.Info(
+			"cloudamqp-connector: recipient is already subscribed to alarm",
+			zap.Int("alarm_id", alarmID),
+			zap.Int("recipient_id", recipientID),
+		)
+
+		return nil, nil
+	}
+
+	before := *alarm
+	alarm.Recipients = append(append([]int(nil), alarm.Recipients...), recipientID)
+
+	change := &auditChange{
+		operation: auditOperationGrant,
+		action:    "update_alarm_recipients",
+		principal: auditPrincipal(principal.Id),
+		target:    entitlement.Id,
+		before:    &before,
+		after:     alarm,
+	}
+
+	err = i.auditLog.track(ctx, change, func() error {
+		return instanceClient.UpdateAlarm(ctx, alarm)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cloudamqp-connector: failed to update alarm %d of instance %d: %w", alarmID, instanceID, err)
+	}
+
+	return nil, nil
+}
+
+func (i *instanceResourceType) unsubscribeFromAlarm(ctx context.Context, g *v2.Grant) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	instanceID, alarmID, recipientID, err := parseAlarmSubscription(ctx, g.Principal.Id, g.Entitlement)
+	if err != nil {
+		return nil, err
+	}
+
+	instanceClient, alarm, err := i.alarm(ctx, instanceID, alarmID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !containsInt(alarm.Recipients, recipientID) {
+		l.Info(
+			"cloudamqp-connector: recipient is already unsubscribed from alarm",
+			zap.Int("alarm_id", alarmID),
+			zap.Int("recipient_id", recipientID),
+		)
+
+		return nil, nil
+	}
+
+	before := *alarm
+
+	recipients := make([]int, 0, len(alarm.Recipients))
+	for _, id := range alarm.Recipients {
+		if id != recipientID {
+			recipients = append(recipients, id)
+		}
+	}
+	alarm.Recipients = recipients
+
+	change := &auditChange{
+		operation: auditOperationRevoke,
+		action:    "update_alarm_recipients",
+		principal: auditPrincipal(g.Principal.Id),
+		target:    g.Entitlement.Id,
+		before:    &before,
+		after:     alarm,
+	}
+
+	err = i.auditLog.track(ctx, change, func() error {
+		return instanceClient.UpdateAlarm(ctx, alarm)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cloudamqp-connector: failed to update alarm %d of instance %d: %w", alarmID, instanceID, err)
+	}
+
+	return nil, nil
+}
+
+func (i *instanceResourceType) deleteIntegration(ctx context.Context, g *v2.Grant) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	principal := g.Principal
+
+	if principal.Id.ResourceType != resourceTypeIntegration.Id {
+		l.Warn(
+			"cloudamqp-connector: only integrations can have data export revoked",
+			zap.String("principal_id", principal.Id.String()),
+			zap.String("principal_type", principal.Id.ResourceType),
+		)
+
+		return nil, fmt.Errorf("cloudamqp-connector: only integrations can have data export revoked")
+	}
+
+	instanceID, kind, integrationID, err := parseIntegrationID(principal.Id.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	if g.Entitlement.Resource.Id.Resource != instanceResourceID(instanceID).Resource {
+		return nil, fmt.Errorf("cloudamqp-connector: integration %s does not belong to instance %s", principal.Id.Resource, g.Entitlement.Resource.Id.Resource)
+	}
+
+	instanceClient, err := i.accounts.InstanceClient(ctx, instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("cloudamqp-connector: failed to get instance %d: %w", instanceID, err)
+	}
+
+	change := &auditChange{
+		operation: auditOperationRevoke,
+		action:    "delete_integration",
+		principal: auditPrincipal(principal.Id),
+		target:    g.Entitlement.Id,
+		before:    &cloudamqp.Integration{Id: integrationID, Kind: kind},
+	}
+
+	err = i.auditLog.track(ctx, change, func() error {
+		return instanceClient.DeleteIntegration(ctx, kind, integrationID)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cloudamqp-connector: failed to delete %s integration %d of instance %d: %w", kind, integrationID, instanceID, err)
+	}
+
+	return nil, nil
+}
+
+func (i *instanceResourceType) alarms(ctx context.Context, instanceID int) ([]cloudamqp.Alarm, error) {
+	instanceClient, err := i.accounts.InstanceClient(ctx, instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("cloudamqp-connector: failed to get instance %d: %w", instanceID, err)
+	}
+
+	alarms, err := instanceClient.GetAlarms(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cloudamqp-connector: failed to list alarms of instance %d: %w", instanceID, err)
+	}
+
+	return alarms, nil
+}
+
+func (i *instanceResourceType) alarm(ctx context.Context, instanceID int, alarmID int) (*cloudamqp.InstanceClient, *cloudamqp.Alarm, error) {
+	instanceClient, err := i.accounts.InstanceClient(ctx, instanceID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cloudamqp-connector: failed to get instance %d: %w", instanceID, err)
+	}
+
+	alarm, err := instanceClient.GetAlarm(ctx, alarmID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cloudamqp-connector: failed to get alarm %d of instance %d: %w", alarmID, instanceID, err)
+	}
+
+	return instanceClient, alarm, nil
+}
+
+func alarmEntitlementSlug(alarmID int) string {
+	return alarmEntitlementPrefix + strconv.Itoa(alarmID)
+}
+
+func alarmDescription(alarm *cloudamqp.Alarm) string {
+	description := fmt.Sprintf("Notifications of the %s alarm", alarm.Type)
+	if alarm.ValueThreshold != 0 {
+		description += fmt.Sprintf(", value threshold %d", alarm.ValueThreshold)
+	}
+	if alarm.TimeThreshold != 0 {
+		description += fmt.Sprintf(", time threshold %ds", alarm.TimeThreshold)
+	}
+	if !alarm.Enabled {
+		description += " (disabled)"
+	}
+
+	return description
+}
+
+// parseAlarmSubscription returns the instance, alarm and recipient IDs of a recipient subscription to an alarm.
+func parseAlarmSubscription(ctx context.Context, principalID *v2.ResourceId, entitlement *v2.Entitlement) (int, int, int, error) {
+	l := ctxzap.Extract(ctx)
+
+	if principalID.ResourceType != resourceTypeAlarmRecipient.Id {
+		l.Warn(
+			"cloudamqp-connector: only alarm recipients can be subscribed to alarms",
+			zap.String("principal_id", principalID.String()),
+			zap.String("principal_type", principalID.ResourceType),
+		)
+
+		return 0, 0, 0, fmt.Errorf("cloudamqp-connector: only alarm recipients can be subscribed to alarms")
+	}
+
+	instanceID, err := parseInstanceID(entitlement.Resource.Id)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	if !strings.HasPrefix(entitlement.Slug, alarmEntitlementPrefix) {
+		return 0, 0, 0, fmt.Errorf("cloudamqp-connector: %s is not an alarm entitlement", entitlement.Id)
+	}
+
+	alarmID, err := strconv.Atoi(strings.TrimPrefix(entitlement.Slug, alarmEntitlementPrefix))
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("cloudamqp-connector: invalid alarm id in %s: %w", entitlement.Id, err)
+	}
+
+	recipientInstanceID, parts, err := parseInstanceScopedID(principalID.Resource, 1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	if recipientInstanceID != instanceID {
+		return 0, 0, 0, fmt.Errorf("cloudamqp-connector: recipient %s does not belong to instance %d", principalID.Resource, instanceID)
+	}
+
+	recipientID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("cloudamqp-connector: invalid recipient id %s: %w", principalID.Resource, err)
+	}
+
+	return instanceID, alarmID, recipientID, nil
+}
+
+func instanceBuilder(accounts *accounts, definitions *brokerDefinitions, auditLog *AuditLog) *instanceResourceType {
+	return &instanceResourceType{
+		resourceType: resourceTypeInstance,
+		accounts:     accounts,
+		definitions:  definitions,
+		auditLog:     auditLog,
+	}
+}
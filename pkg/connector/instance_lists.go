@@ -0,0 +1,118 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
+)
+
+// instanceLists keeps lists fetched from the instance API once per sync, so that the grants of every child of an
+// instance are computed from a single request. Grants and revokes fetch the lists fresh and drop them once changed.
+type instanceLists struct {
+	accounts *accounts
+
+	mtx           sync.Mutex
+	firewallRules map[int][]cloudamqp.FirewallRule
+	plugins       map[int][]cloudamqp.Plugin
+}
+
+func newInstanceLists(accounts *accounts) *instanceLists {
+	return &instanceLists{
+		accounts:      accounts,
+		firewallRules: make(map[int][]cloudamqp.FirewallRule),
+		plugins:       make(map[int][]cloudamqp.Plugin),
+	}
+}
+
+// Reset drops the lists of every instance, so that they are fetched again by the next sync.
+func (l *instanceLists) Reset() {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.firewallRules = make(map[int][]cloudamqp.FirewallRule)
+	l.plugins = make(map[int][]cloudamqp.Plugin)
+}
+
+// FirewallRules returns the firewall rules of provided instance, fetching them on first use during the sync.
+// The returned rules must not be changed.
+func (l *instanceLists) FirewallRules(ctx context.Context, instanceID int) ([]cloudamqp.FirewallRule, error) {
+	l.mtx.Lock()
+	rules, ok := l.firewallRules[instanceID]
+	l.mtx.Unlock()
+
+	if ok {
+		return rules, nil
+	}
+
+	rules, err := l.fetchFirewallRules(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	l.mtx.Lock()
+	l.firewallRules[instanceID] = rules
+	l.mtx.Unlock()
+
+	return rules, nil
+}
+
+// InvalidateFirewallRules drops the firewall rules of provided instance, after they were changed.
+func (l *instanceLists) InvalidateFirewallRules(instanceID int) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	delete(l.firewallRules, instanceID)
+}
+
+// fetchFirewallRules fetches the firewall rules of provided instance, bypassing the rules kept for the sync.
+func (l *instanceLists) fetchFirewallRules(ctx context.Context, instanceID int) ([]cloudamqp.FirewallRule, error) {
+	instanceClient, err := l.accounts.InstanceClient(ctx, instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("cloudamqp-connector: failed to get instance %d: %w", instanceID, err)
+	}
+
+	rules, err := instanceClient.GetFirewallRules(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cloudamqp-connector: failed to list firewall rules of instance %d: %w", instanceID, err)
+	}
+
+	return rules, nil
+}
+
+// Plugins returns the plugins available on provided instance, fetching them on first use during the sync.
+// The returned plugins must not be changed.
+func (l *instanceLists) Plugins(ctx context.Context, instanceID int) ([]cloudamqp.Plugin, error) {
+	l.mtx.Lock()
+	plugins, ok := l.plugins[instanceID]
+	l.mtx.Unlock()
+
+	if ok {
+		return plugins, nil
+	}
+
+	instanceClient, err := l.accounts.InstanceClient(ctx, instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("cloudamqp-connector: failed to get instance %d: %w", instanceID, err)
+	}
+
+	plugins, err = instanceClient.GetPlugins(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cloudamqp-connector: failed to list plugins of instance %d: %w", instanceID, err)
+	}
+
+	l.mtx.Lock()
+	l.plugins[instanceID] = plugins
+	l.mtx.Unlock()
+
+	return plugins, nil
+}
+
+// InvalidatePlugins drops the plugins of provided instance, after one was enabled or disabled.
+func (l *instanceLists) InvalidatePlugins(instanceID int) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	delete(l.plugins, instanceID)
+}
@@ -0,0 +1,102 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+)
+
+// countingClient returns a client answering GET requests of provided URLs with provided JSON bodies, and the number
+// of requests sent to each URL.
+func countingClient(t *testing.T, bodies map[string]string) (*cloudamqp.Client, map[string]int) {
+	t.Helper()
+
+	requests := make(map[string]int)
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requests[req.URL.String()]++
+
+		body, ok := bodies[req.URL.String()]
+		if !ok || req.Method != http.MethodGet {
+			t.Errorf("unexpected request %s %s", req.Method, req.URL)
+			return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}}, nil
+		}
+
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Header: http.Header{}}, nil
+	})
+
+	return cloudamqp.NewClient(&http.Client{Transport: transport}, "token"), requests
+}
+
+func TestFirewallGrantsListedOncePerSync(t *testing.T) {
+	client, requests := countingClient(t, map[string]string{
+		fmt.Sprintf(cloudamqp.InstanceBaseURL, 1): `{"id": 1, "apikey": "key"}`,
+		cloudamqp.FirewallBaseURL: `[
+			{"ip": "10.0.0.0/24", "services": ["AMQPS", "HTTPS"]},
+			{"ip": "192.168.0.1/32", "services": ["MQTTS"]}
+		]`,
+	})
+	accounts := newAccounts([]*account{{client: client}}, nil)
+	lists := newInstanceLists(accounts)
+	firewall := firewallRuleBuilder(accounts, lists, nil)
+
+	ctx := context.Background()
+	syncGrants := func() {
+		for ip, services := range map[string]int{"10.0.0.0/24": 2, "192.168.0.1/32": 1, "10.0.0.1/32": 0} {
+			resource := &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeFirewallRule.Id, Resource: instanceScopedID(1, ip)}}
+			grants, _, _, err := firewall.Grants(ctx, resource, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(grants) != services {
+				t.Errorf("rule %s has %d grants, want %d", ip, len(grants), services)
+			}
+		}
+	}
+
+	syncGrants()
+	if requests[cloudamqp.FirewallBaseURL] != 1 {
+		t.Errorf("firewall rules listed %d times during the sync, want once", requests[cloudamqp.FirewallBaseURL])
+	}
+
+	lists.Reset()
+	syncGrants()
+	if requests[cloudamqp.FirewallBaseURL] != 2 {
+		t.Errorf("firewall rules not listed again by the next sync")
+	}
+}
+
+func TestPluginGrantsListedOncePerSync(t *testing.T) {
+	client, requests := countingClient(t, map[string]string{
+		fmt.Sprintf(cloudamqp.InstanceBaseURL, 1): `{"id": 1, "apikey": "key"}`,
+		cloudamqp.PluginsBaseURL: `[
+			{"name": "rabbitmq_shovel", "enabled": true},
+			{"name": "rabbitmq_federation", "enabled": false}
+		]`,
+	})
+	accounts := newAccounts([]*account{{client: client}}, nil)
+	plugins := pluginBuilder(accounts, newInstanceLists(accounts), nil)
+
+	ctx := context.Background()
+	for name, enabled := range map[string]int{"rabbitmq_shovel": 1, "rabbitmq_federation": 0, "rabbitmq_mqtt": 0} {
+		resource := &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypePlugin.Id, Resource: instanceScopedID(1, name)}}
+		grants, _, _, err := plugins.Grants(ctx, resource, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(grants) != enabled {
+			t.Errorf("plugin %s has %d grants, want %d", name, len(grants), enabled)
+		}
+	}
+
+	if requests[cloudamqp.PluginsBaseURL] != 1 {
+		t.Errorf("plugins listed %d times during the sync, want once", requests[cloudamqp.PluginsBaseURL])
+	}
+}
@@ -0,0 +1,46 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+)
+
+func TestUnsubscribeUnsubscribedRecipient(t *testing.T) {
+	client, requests := countingClient(t, map[string]string{
+		fmt.Sprintf(cloudamqp.InstanceBaseURL, 1): `{"id": 1, "apikey": "key"}`,
+		fmt.Sprintf(cloudamqp.AlarmBaseURL, 3):    `{"id": 3, "type": "cpu", "recipients": [5]}`,
+	})
+	auditPath := filepath.Join(t.TempDir(), "audit.jsonl")
+	accounts := newAccounts([]*account{{client: client}}, nil)
+	instances := instanceBuilder(accounts, newBrokerDefinitions(accounts, nil, 1, 0), NewAuditLog(auditPath, false))
+
+	instance := &v2.Resource{Id: instanceResourceID(1)}
+	g := &v2.Grant{
+		Entitlement: &v2.Entitlement{
+			Id:       ent.NewEntitlementID(instance, alarmEntitlementSlug(3)),
+			Resource: instance,
+			Slug:     alarmEntitlementSlug(3),
+		},
+		Principal: &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeAlarmRecipient.Id, Resource: instanceScopedID(1, "7")}},
+	}
+
+	if _, err := instances.Revoke(context.Background(), g); err != nil {
+		t.Fatal(err)
+	}
+
+	if requests[fmt.Sprintf(cloudamqp.AlarmBaseURL, 3)] != 1 {
+		t.Errorf("alarm was requested %d times, want once", requests[fmt.Sprintf(cloudamqp.AlarmBaseURL, 3)])
+	}
+
+	if _, err := os.Stat(auditPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("audit log was written for a recipient already unsubscribed: %v", err)
+	}
+}
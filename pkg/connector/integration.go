@@ -0,0 +1,133 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+)
+
+type integrationResourceType struct {
+	resourceType *v2.ResourceType
+	accounts     *accounts
+}
+
+func (i *integrationResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return i.resourceType
+}
+
+// integrationResource creates a new non-human identity for a third-party log or metric integration of a CloudAMQP Instance.
+func integrationResource(instanceID int, integration *cloudamqp.Integration) (*v2.Resource, error) {
+	profile := map[string]interface{}{
+		"integration_id": integration.Id,
+		"kind":           integration.Kind,
+		"destination":    integration.Type,
+		"region":         integration.Region,
+	}
+
+	resource, err := rs.NewUserResource(
+		fmt.Sprintf("%s %s", titleCase(integration.Type), integration.Kind),
+		resourceTypeIntegration,
+		integrationID(instanceID, integration.Kind, integration.Id),
+		[]rs.UserTraitOption{
+			rs.WithUserProfile(profile),
+			rs.WithStatus(v2.UserTrait_Status_STATUS_ENABLED),
+			rs.WithAccountType(v2.UserTrait_ACCOUNT_TYPE_SERVICE),
+		},
+		rs.WithParentResourceID(instanceResourceID(instanceID)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+func (i *integrationResourceType) List(ctx context.Context, parentID *v2.ResourceId, pt *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentID == nil || parentID.ResourceType != resourceTypeInstance.Id {
+		return nil, "", nil, nil
+	}
+
+	instanceID, err := parseInstanceID(parentID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	integrations, err := listIntegrations(ctx, i.accounts, instanceID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	rv := make([]*v2.Resource, 0, len(integrations))
+	for _, integration := range integrations {
+		integrationCopy := integration
+
+		ir, err := integrationResource(instanceID, &integrationCopy)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rv = append(rv, ir)
+	}
+
+	return rv, "", nil, nil
+}
+
+func (i *integrationResourceType) Entitlements(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+func (i *integrationResourceType) Grants(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+// listIntegrations returns both log and metric integrations of provided instance.
+func listIntegrations(ctx context.Context, accounts *accounts, instanceID int) ([]cloudamqp.Integration, error) {
+	instanceClient, err := accounts.InstanceClient(ctx, instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("cloudamqp-connector: failed to get instance %d: %w", instanceID, err)
+	}
+
+	logIntegrations, err := instanceClient.GetLogIntegrations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cloudamqp-connector: failed to list log integrations of instance %d: %w", instanceID, err)
+	}
+
+	metricIntegrations, err := instanceClient.GetMetricIntegrations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cloudamqp-connector: failed to list metric integrations of instance %d: %w", instanceID, err)
+	}
+
+	return append(logIntegrations, metricIntegrations...), nil
+}
+
+func integrationID(instanceID int, kind string, id int) string {
+	return instanceScopedID(instanceID, kind, strconv.Itoa(id))
+}
+
+// parseIntegrationID returns the instance ID, kind and integration ID of provided integration resource ID.
+func parseIntegrationID(id string) (int, string, int, error) {
+	instanceID, parts, err := parseInstanceScopedID(id, 2)
+	if err != nil {
+		return 0, "", 0, err
+	}
+
+	integrationID, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, "", 0, fmt.Errorf("cloudamqp-connector: invalid integration id %s: %w", id, err)
+	}
+
+	return instanceID, parts[0], integrationID, nil
+}
+
+func integrationBuilder(accounts *accounts) *integrationResourceType {
+	return &integrationResourceType{
+		resourceType: resourceTypeIntegration,
+		accounts:     accounts,
+	}
+}
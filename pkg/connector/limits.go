@@ -0,0 +1,256 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// Broker users and vhosts without any limit hold this entitlement.
+const unlimitedEntitlement = "unlimited"
+
+// DefaultLimits are set on broker users and vhosts when their unlimited entitlement is revoked.
+type DefaultLimits struct {
+	User  cloudamqp.Limits
+	Vhost cloudamqp.Limits
+}
+
+// brokerLimits are the limits of users and vhosts of a broker, keyed by their names.
+type brokerLimits struct {
+	users  map[string]cloudamqp.Limits
+	vhosts map[string]cloudamqp.Limits
+}
+
+// Limits returns limits of the broker running on provided instance, or nil when they can not be known: brokers
+// synced from a definitions file, brokers failing to be fetched during the sync, brokers not supporting limits and
+// brokers whose limits failed to be fetched. Failing to fetch limits leaves the rest of the broker data synced.
+func (b *brokerDefinitions) Limits(ctx context.Context, instanceID int) (*brokerLimits, error) {
+	if b.ReadOnly(instanceID) || b.Failure(instanceID) != nil || b.LimitsFailure(instanceID) != nil {
+		return nil, nil
+	}
+
+	b.mtx.Lock()
+	cached, ok := b.limits[instanceID]
+	b.mtx.Unlock()
+
+	if ok {
+		return cached, nil
+	}
+
+	limits, err := b.fetchLimits(ctx, instanceID)
+	if err != nil {
+		b.failLimits(ctx, instanceID, err)
+		return nil, nil
+	}
+
+	b.mtx.Lock()
+	b.limits[instanceID] = limits
+	b.mtx.Unlock()
+
+	return limits, nil
+}
+
+// LimitsFailure returns the error limits of the broker running on provided instance failed to be fetched with during
+// the sync, if any.
+func (b *brokerDefinitions) LimitsFailure(instanceID int) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return b.limitFailures[instanceID]
+}
+
+// failLimits records that limits of the broker of provided instance failed to be fetched, so that they are not
+// fetched again until the sync is over.
+func (b *brokerDefinitions) failLimits(ctx context.Context, instanceID int, err error) {
+	ctxzap.Extract(ctx).Warn(
+		"cloudamqp-connector: failed to fetch broker limits, syncing broker users and vhosts without their limits",
+		zap.Int("instance_id", instanceID),
+		zap.Error(err),
+	)
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.limitFailures[instanceID] = err
+}
+
+// InvalidateLimits drops cached limits of provided instance and their failure, so that they are exported again on next
+// use while the definitions of the broker are kept.
+func (b *brokerDefinitions) InvalidateLimits(instanceID int) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	delete(b.limits, instanceID)
+	delete(b.limitFailures, instanceID)
+}
+
+// fetchLimits exports limits of the broker running on provided instance, failing when the broker can not be reached.
+// Provisioning uses it to change limits from their current values rather than those cached for the sync.
+func (b *brokerDefinitions) fetchLimits(ctx context.Context, instanceID int) (*brokerLimits, error) {
+	ctx, cancel := b.withInstanceTimeout(ctx)
+	defer cancel()
+
+	brokerClient, err := broker(ctx, b.accounts, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	userLimits, err := brokerClient.GetUserLimits(ctx)
+	if err != nil {
+		if errors.Is(err, cloudamqp.ErrUnsupportedByBroker) {
+			ctxzap.Extract(ctx).Debug("cloudamqp-connector: broker has no limits", zap.Int("instance_id", instanceID), zap.Error(err))
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("cloudamqp-connector: failed to get user limits of instance %d: %w", instanceID, err)
+	}
+
+	vhostLimits, err := brokerClient.GetVhostLimits(ctx)
+	if err != nil {
+		if errors.Is(err, cloudamqp.ErrUnsupportedByBroker) {
+			ctxzap.Extract(ctx).Debug("cloudamqp-connector: broker has no limits", zap.Int("instance_id", instanceID), zap.Error(err))
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("cloudamqp-connector: failed to get vhost limits of instance %d: %w", instanceID, err)
+	}
+
+	limits := &brokerLimits{
+		users:  make(map[string]cloudamqp.Limits, len(userLimits)),
+		vhosts: make(map[string]cloudamqp.Limits, len(vhostLimits)),
+	}
+
+	for _, user := range userLimits {
+		limits.users[user.User] = user.Value
+	}
+
+	for _, vhost := range vhostLimits {
+		limits.vhosts[vhost.Vhost] = vhost.Value
+	}
+
+	return limits, nil
+}
+
+// limitsProfile adds the limits of a broker user to its profile.
+func limitsProfile(profile map[string]interface{}, limits cloudamqp.Limits) {
+	values := make(map[string]interface{}, len(limits))
+	for name, value := range limits {
+		if value >= 0 {
+			values[name] = value
+		}
+	}
+
+	profile["limits"] = values
+	profile["unlimited"] = limits.Unlimited()
+}
+
+// limitsDescription adds the limits of a vhost to its description.
+func limitsDescription(description string, limits cloudamqp.Limits) string {
+	var values []string
+	for _, name := range sortedLimitNames(limits) {
+		if limits[name] >= 0 {
+			values = append(values, fmt.Sprintf("%s %d", name, limits[name]))
+		}
+	}
+
+	text := "Unlimited"
+	if len(values) > 0 {
+		text = fmt.Sprintf("Limits: %s", strings.Join(values, ", "))
+	}
+
+	if description == "" {
+		return text
+	}
+
+	return fmt.Sprintf("%s (%s)", description, text)
+}
+
+// unlimitedEntitlementFor creates the unlimited entitlement of a broker user or vhost, held by the resource itself.
+func unlimitedEntitlementFor(resource *v2.Resource, resourceType *v2.ResourceType, kindOfResource string) *v2.Entitlement {
+	return ent.NewPermissionEntitlement(
+		resource,
+		unlimitedEntitlement,
+		ent.WithGrantableTo(resourceType),
+		ent.WithDisplayName(fmt.Sprintf("%s %s unlimited", resource.DisplayName, kindOfResource)),
+		ent.WithDescription(fmt.Sprintf("No limits are set on %s %s", kindOfResource, resource.DisplayName)),
+	)
+}
+
+// unlimitedGrant grants the unlimited entitlement to a broker user or vhost without limits.
+func unlimitedGrant(resource *v2.Resource, limits cloudamqp.Limits) []*v2.Grant {
+	if !limits.Unlimited() {
+		return nil
+	}
+
+	return []*v2.Grant{grant.NewGrant(resource, unlimitedEntitlement, resource.Id)}
+}
+
+// checkUnlimitedPrincipal validates that the unlimited entitlement is provisioned for the resource it belongs to.
+func checkUnlimitedPrincipal(principalID *v2.ResourceId, entitlement *v2.Entitlement) error {
+	if principalID.ResourceType != entitlement.Resource.Id.ResourceType || principalID.Resource != entitlement.Resource.Id.Resource {
+		return fmt.Errorf("cloudamqp-connector: only %s %s can be unlimited by its entitlement", entitlement.Resource.Id.ResourceType, entitlement.Resource.Id.Resource)
+	}
+
+	return nil
+}
+
+// limitsSetter changes the limits of a broker user or vhost.
+type limitsSetter struct {
+	set   func(ctx context.Context, name string, value int64) error
+	clear func(ctx context.Context, name string) error
+}
+
+// applyLimits changes current limits into provided ones, clearing limits left out of them.
+func (s *limitsSetter) applyLimits(ctx context.Context, current cloudamqp.Limits, limits cloudamqp.Limits) error {
+	for _, name := range sortedLimitNames(current) {
+		if current[name] < 0 {
+			continue
+		}
+
+		if value, ok := limits[name]; ok && value >= 0 {
+			continue
+		}
+
+		err := s.clear(ctx, name)
+		if err != nil {
+			return fmt.Errorf("failed to clear %s: %w", name, err)
+		}
+	}
+
+	for _, name := range sortedLimitNames(limits) {
+		value := limits[name]
+		if value < 0 {
+			continue
+		}
+
+		if currentValue, ok := current[name]; ok && currentValue == value {
+			continue
+		}
+
+		err := s.set(ctx, name, value)
+		if err != nil {
+			return fmt.Errorf("failed to set %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func sortedLimitNames(limits cloudamqp.Limits) []string {
+	names := make([]string, 0, len(limits))
+	for name := range limits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,112 @@
+package connector
+
+import (
+	"context"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	connectorDescription = "Syncs CloudAMQP team accounts, their users, roles, VPCs and instances, along with users, " +
+		"virtual hosts, queues, exchanges, policies, shovels and federation upstreams of the RabbitMQ and LavinMQ brokers " +
+		"running on the instances."
+	connectorHelpURL = "https://github.com/conductorone/baton-cloudamqp"
+)
+
+// Whether entitlements of a resource type can be requested.
+const (
+	resourceTypeProvisionable = "provisionable"
+	resourceTypeSyncOnly      = "sync_only"
+)
+
+// provisioningCapabilities are the capabilities the API keys need to provision each resource type.
+var provisioningCapabilities = map[string]string{
+	resourceTypeAccount.Id:      CapabilityWriteTeam,
+	resourceTypeRole.Id:         CapabilityWriteTeam,
+	resourceTypeInstance.Id:     CapabilityInstanceAPI,
+	resourceTypeFirewallRule.Id: CapabilityInstanceAPI,
+	resourceTypePlugin.Id:       CapabilityInstanceAPI,
+	resourceTypeVhost.Id:        CapabilityManagementAPI,
+	resourceTypeBrokerUser.Id:   CapabilityManagementAPI,
+}
+
+// Metadata returns metadata about the connector: the accounts it is connected to and which resource types can be
+// provisioned, given the capabilities the last validation found missing. Capabilities are probed when no validation
+// ran yet.
+func (pd *CloudAMQP) Metadata(ctx context.Context) (*v2.ConnectorMetadata, error) {
+	profile, err := structpb.NewStruct(map[string]interface{}{
+		"accounts":       pd.accountsProfile(ctx),
+		"resource_types": pd.resourceTypesProfile(ctx),
+		"dry_run":        pd.dryRun,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &v2.ConnectorMetadata{
+		DisplayName: "CloudAMQP",
+		Description: connectorDescription,
+		HelpUrl:     connectorHelpURL,
+		Profile:     profile,
+	}, nil
+}
+
+// accountsProfile lists the accounts the connector is connected to. Failing to identify an account is only logged,
+// so that syncs do not depend on it.
+func (pd *CloudAMQP) accountsProfile(ctx context.Context) []interface{} {
+	rv := make([]interface{}, 0, len(pd.accounts.list))
+	for _, acc := range pd.accounts.list {
+		profile := map[string]interface{}{
+			"resource_id": pd.accounts.AccountID(acc),
+		}
+
+		if acc.name != "" {
+			profile["name"] = acc.name
+		}
+
+		identity, err := pd.accounts.Identity(ctx, acc)
+		if err != nil {
+			ctxzap.Extract(ctx).Warn(
+				"cloudamqp-connector: failed to identify account",
+				zap.String("account", acc.name),
+				zap.Error(err),
+			)
+		}
+
+		if identity != nil {
+			profile["account_id"] = string(identity.Id)
+			profile["account_name"] = identity.Name
+		}
+
+		rv = append(rv, profile)
+	}
+
+	return rv
+}
+
+// resourceTypesProfile tells for each resource type whether its entitlements can be requested, or only synced.
+func (pd *CloudAMQP) resourceTypesProfile(ctx context.Context) map[string]interface{} {
+	capabilities := pd.capabilityReport(ctx)
+
+	rv := make(map[string]interface{})
+	for _, syncer := range pd.ResourceSyncers(ctx) {
+		resourceType := syncer.ResourceType(ctx)
+
+		_, provisionable := syncer.(connectorbuilder.ResourceProvisioner)
+		if capability, ok := provisioningCapabilities[resourceType.Id]; ok {
+			provisionable = provisionable && !capabilities.Lacks(capability)
+		}
+
+		if provisionable {
+			rv[resourceType.Id] = resourceTypeProvisionable
+		} else {
+			rv[resourceType.Id] = resourceTypeSyncOnly
+		}
+	}
+
+	return rv
+}
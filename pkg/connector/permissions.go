@@ -0,0 +1,156 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+const (
+	permissionConfigure = "configure"
+	permissionWrite     = "write"
+	permissionRead      = "read"
+)
+
+// RabbitMQ checks permissions of the default exchange under this name.
+const defaultExchangePermissionName = "amq.default"
+
+var brokerPermissions = []string{
+	permissionConfigure, permissionWrite, permissionRead,
+}
+
+// permissionPattern returns the regular expression of provided permission kind.
+func permissionPattern(permission *cloudamqp.Permission, kind string) string {
+	switch kind {
+	case permissionConfigure:
+		return permission.Configure
+	case permissionWrite:
+		return permission.Write
+	case permissionRead:
+		return permission.Read
+	default:
+		return ""
+	}
+}
+
+// permissionEntitlements creates configure, write and read entitlements grantable to broker users.
+func permissionEntitlements(resource *v2.Resource, kindOfResource string) []*v2.Entitlement {
+	rv := make([]*v2.Entitlement, 0, len(brokerPermissions))
+	for _, permission := range brokerPermissions {
+		rv = append(rv, ent.NewPermissionEntitlement(
+			resource,
+			permission,
+			ent.WithGrantableTo(resourceTypeBrokerUser),
+			ent.WithDisplayName(fmt.Sprintf("%s %s %s", resource.DisplayName, kindOfResource, permission)),
+			ent.WithDescription(fmt.Sprintf("%s access to %s %s", titleCase(permission), kindOfResource, resource.DisplayName)),
+		))
+	}
+
+	return rv
+}
+
+// topicPermissionPattern returns the routing key regular expression of provided permission kind, if any.
+func topicPermissionPattern(permission *cloudamqp.TopicPermission, kind string) string {
+	switch kind {
+	case permissionWrite:
+		return permission.Write
+	case permissionRead:
+		return permission.Read
+	default:
+		return ""
+	}
+}
+
+// effectivePermissionGrants evaluates permission regexes of the vhost against the name of a queue or exchange
+// and grants every matching permission, keeping the matched pattern as provenance of the grant. Topic permissions
+// of an exchange restrict the routing keys of write and read grants, kept as their routing key pattern; users
+// without topic permissions on the exchange may use any routing key.
+func effectivePermissionGrants(
+	ctx context.Context,
+	instanceID int,
+	resource *v2.Resource,
+	name string,
+	permissions []cloudamqp.Permission,
+	topicPermissions []cloudamqp.TopicPermission,
+) []*v2.Grant {
+	l := ctxzap.Extract(ctx)
+
+	topicByUser := make(map[string]*cloudamqp.TopicPermission, len(topicPermissions))
+	for i := range topicPermissions {
+		topicByUser[topicPermissions[i].User] = &topicPermissions[i]
+	}
+
+	var rv []*v2.Grant
+	for _, permission := range permissions {
+		permissionCopy := permission
+
+		for _, kind := range brokerPermissions {
+			pattern := permissionPattern(&permissionCopy, kind)
+			if pattern == "" {
+				continue
+			}
+
+			matcher, err := regexp.Compile(pattern)
+			if err != nil {
+				l.Warn(
+					"cloudamqp-connector: skipping permission pattern that can not be evaluated",
+					zap.String("user", permission.User),
+					zap.String("vhost", permission.Vhost),
+					zap.String("pattern", pattern),
+					zap.Error(err),
+				)
+
+				continue
+			}
+
+			if !matcher.MatchString(name) {
+				continue
+			}
+
+			routingKeyPattern := ""
+			if topic, ok := topicByUser[permission.User]; ok {
+				routingKeyPattern = topicPermissionPattern(topic, kind)
+			}
+
+			rv = append(rv, permissionGrant(instanceID, resource, kind, &permissionCopy, routingKeyPattern))
+		}
+	}
+
+	return rv
+}
+
+// permissionGrant grants a permission of a broker user, keeping its pattern and the routing key pattern of its topic
+// permission, if any, as metadata.
+func permissionGrant(instanceID int, resource *v2.Resource, kind string, permission *cloudamqp.Permission, routingKeyPattern string) *v2.Grant {
+	metadata := map[string]interface{}{
+		"vhost":   permission.Vhost,
+		"pattern": permissionPattern(permission, kind),
+	}
+	if routingKeyPattern != "" {
+		metadata["routing_key_pattern"] = routingKeyPattern
+	}
+
+	return grant.NewGrant(
+		resource,
+		kind,
+		brokerUserResourceID(instanceID, permission.User),
+		grant.WithGrantMetadata(metadata),
+	)
+}
+
+// broker returns a client for the management API of the broker running on provided instance.
+func broker(ctx context.Context, accounts *accounts, instanceID int) (cloudamqp.Broker, error) {
+	brokerClient, err := accounts.Broker(ctx, instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("cloudamqp-connector: failed to get management api of instance %d: %w", instanceID, err)
+	}
+
+	return brokerClient, nil
+}
@@ -0,0 +1,149 @@
+package connector
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestEffectivePermissionGrants(t *testing.T) {
+	tests := []struct {
+		name        string
+		queue       string
+		permissions []cloudamqp.Permission
+		want        []string
+	}{
+		{
+			name:        "full access",
+			queue:       "orders",
+			permissions: []cloudamqp.Permission{{User: "app", Vhost: "/", Configure: ".*", Write: ".*", Read: ".*"}},
+			want:        []string{"app configure", "app read", "app write"},
+		},
+		{
+			name:        "empty pattern grants nothing",
+			queue:       "orders",
+			permissions: []cloudamqp.Permission{{User: "app", Vhost: "/", Configure: "", Write: "", Read: ".*"}},
+			want:        []string{"app read"},
+		},
+		{
+			name:        "anchored prefix",
+			queue:       "orders.eu",
+			permissions: []cloudamqp.Permission{{User: "app", Vhost: "/", Configure: "^billing\\.", Write: "^orders\\.", Read: "^orders\\."}},
+			want:        []string{"app read", "app write"},
+		},
+		{
+			name:        "patterns are not anchored",
+			queue:       "eu.orders.v2",
+			permissions: []cloudamqp.Permission{{User: "app", Vhost: "/", Read: "orders"}},
+			want:        []string{"app read"},
+		},
+		{
+			name:        "match nothing",
+			queue:       "orders",
+			permissions: []cloudamqp.Permission{{User: "app", Vhost: "/", Configure: "^$", Write: "^$", Read: "^$"}},
+			want:        nil,
+		},
+		{
+			name:  "invalid pattern is skipped",
+			queue: "orders",
+			permissions: []cloudamqp.Permission{
+				{User: "broken", Vhost: "/", Read: "("},
+				{User: "app", Vhost: "/", Read: "^orders$"},
+			},
+			want: []string{"app read"},
+		},
+		{
+			name:  "several users",
+			queue: "amq.gen-123",
+			permissions: []cloudamqp.Permission{
+				{User: "consumer", Vhost: "/", Configure: "^amq\\.gen", Read: ".*"},
+				{User: "producer", Vhost: "/", Write: "^amq\\.gen"},
+			},
+			want: []string{"consumer configure", "consumer read", "producer write"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource, err := queueResource(42, &cloudamqp.Queue{Name: tt.queue, Vhost: "/"}, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, g := range effectivePermissionGrants(context.Background(), 42, resource, tt.queue, tt.permissions, nil) {
+				_, parts, err := parseInstanceScopedID(g.Principal.Id.Resource, 1)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				kind := ""
+				for _, permission := range brokerPermissions {
+					if g.Entitlement.Id == ent.NewEntitlementID(resource, permission) {
+						kind = permission
+					}
+				}
+
+				got = append(got, parts[0]+" "+kind)
+			}
+			sort.Strings(got)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("effectivePermissionGrants() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEffectivePermissionGrantsTopicPermissions(t *testing.T) {
+	resource, err := exchangeResource(42, &cloudamqp.Exchange{Name: "events", Vhost: "/", Type: "topic"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	permissions := []cloudamqp.Permission{
+		{User: "app", Vhost: "/", Write: ".*", Read: ".*"},
+		{User: "other", Vhost: "/", Write: ".*"},
+	}
+	topicPermissions := []cloudamqp.TopicPermission{
+		{User: "app", Vhost: "/", Exchange: "events", Write: "^orders\\.", Read: ""},
+	}
+
+	got := make(map[string]string)
+	for _, g := range effectivePermissionGrants(context.Background(), 42, resource, "events", permissions, topicPermissions) {
+		_, parts, err := parseInstanceScopedID(g.Principal.Id.Resource, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		metadata := &structpb.Struct{}
+		grantAnnos := annotations.Annotations(g.Annotations)
+		if _, err := grantAnnos.Pick(metadata); err != nil {
+			t.Fatal(err)
+		}
+
+		kind := ""
+		for _, permission := range brokerPermissions {
+			if g.Entitlement.Id == ent.NewEntitlementID(resource, permission) {
+				kind = permission
+			}
+		}
+
+		got[parts[0]+" "+kind] = metadata.GetFields()["routing_key_pattern"].GetStringValue()
+	}
+
+	want := map[string]string{
+		"app write":   "^orders\\.",
+		"app read":    "",
+		"other write": "",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("routing key patterns = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,239 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+const pluginEnabled = "enabled"
+
+type pluginResourceType struct {
+	resourceType *v2.ResourceType
+	accounts     *accounts
+	lists        *instanceLists
+	auditLog     *AuditLog
+}
+
+func (p *pluginResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return p.resourceType
+}
+
+// pluginResource creates a new connector resource for a RabbitMQ plugin available on a CloudAMQP Instance.
+func pluginResource(instanceID int, plugin *cloudamqp.Plugin) (*v2.Resource, error) {
+	resource, err := rs.NewResource(
+		plugin.Name,
+		resourceTypePlugin,
+		instanceScopedID(instanceID, plugin.Name),
+		rs.WithParentResourceID(instanceResourceID(instanceID)),
+		rs.WithDescription(plugin.Description),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+func (p *pluginResourceType) List(ctx context.Context, parentID *v2.ResourceId, pt *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentID == nil || parentID.ResourceType != resourceTypeInstance.Id {
+		return nil, "", nil, nil
+	}
+
+	instanceID, err := parseInstanceID(parentID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	plugins, err := p.lists.Plugins(ctx, instanceID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	rv := make([]*v2.Resource, 0, len(plugins))
+	for _, plugin := range plugins {
+		pluginCopy := plugin
+
+		pr, err := pluginResource(instanceID, &pluginCopy)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rv = append(rv, pr)
+	}
+
+	return rv, "", nil, nil
+}
+
+func (p *pluginResourceType) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	var rv []*v2.Entitlement
+
+	entitlementOptions := []ent.EntitlementOption{
+		ent.WithGrantableTo(resourceTypeInstance),
+		ent.WithDisplayName(fmt.Sprintf("%s plugin enabled", resource.DisplayName)),
+		ent.WithDescription(fmt.Sprintf("%s plugin is enabled on the instance", resource.DisplayName)),
+	}
+
+	rv = append(rv, ent.NewPermissionEntitlement(resource, pluginEnabled, entitlementOptions...))
+
+	return rv, "", nil, nil
+}
+
+// Grants returns whether the plugin is enabled, from the plugins of its instance listed once per sync.
+func (p *pluginResourceType) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	instanceID, parts, err := parseInstanceScopedID(resource.Id.Resource, 1)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	plugins, err := p.lists.Plugins(ctx, instanceID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	var rv []*v2.Grant
+	for _, plugin := range plugins {
+		if plugin.Name == parts[0] && plugin.Enabled {
+			rv = append(rv, grant.NewGrant(resource, pluginEnabled, instanceResourceID(instanceID)))
+		}
+	}
+
+	return rv, "", nil, nil
+}
+
+// Grant enables the plugin on the instance.
+func (p *pluginResourceType) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) (annotations.Annotations, error) {
+	instanceID, instanceClient, name, err := p.parsePluginEntitlement(ctx, principal.Id, entitlement)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.setPluginEnabled(ctx, auditOperationGrant, principal.Id, entitlement, instanceID, instanceClient, name, true)
+	if err != nil {
+		return nil, fmt.Errorf("cloudamqp-connector: failed to enable plugin %s: %w", name, err)
+	}
+
+	return nil, nil
+}
+
+// Revoke disables the plugin on the instance.
+func (p *pluginResourceType) Revoke(ctx context.Context, g *v2.Grant) (annotations.Annotations, error) {
+	instanceID, instanceClient, name, err := p.parsePluginEntitlement(ctx, g.Principal.Id, g.Entitlement)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.setPluginEnabled(ctx, auditOperationRevoke, g.Principal.Id, g.Entitlement, instanceID, instanceClient, name, false)
+	if err != nil {
+		return nil, fmt.Errorf("cloudamqp-connector: failed to disable plugin %s: %w", name, err)
+	}
+
+	return nil, nil
+}
+
+// setPluginEnabled enables or disables the plugin, recording whether it was enabled before in the audit log. Plugins are
+// fetched fresh rather than from the ones listed for the sync, which are dropped once the plugin is changed.
+func (p *pluginResourceType) setPluginEnabled(
+	ctx context.Context,
+	operation string,
+	principalID *v2.ResourceId,
+	entitlement *v2.Entitlement,
+	instanceID int,
+	instanceClient *cloudamqp.InstanceClient,
+	name string,
+	enabled bool,
+) error {
+	plugins, err := instanceClient.GetPlugins(ctx)
+	if err != nil {
+		return err
+	}
+
+	var before *cloudamqp.Plugin
+	for i := range plugins {
+		if plugins[i].Name == name {
+			before = &plugins[i]
+			break
+		}
+	}
+
+	if before == nil {
+		return fmt.Errorf("plugin is not available on the instance")
+	}
+
+	after := *before
+	after.Enabled = enabled
+
+	change := &auditChange{
+		operation: operation,
+		action:    "update_plugin",
+		principal: auditPrincipal(principalID),
+		target:    entitlement.Id,
+		before:    before,
+		after:     &after,
+	}
+
+	err = p.auditLog.track(ctx, change, func() error {
+		if enabled {
+			return instanceClient.EnablePlugin(ctx, name)
+		}
+
+		return instanceClient.DisablePlugin(ctx, name)
+	})
+	p.lists.InvalidatePlugins(instanceID)
+
+	return err
+}
+
+// parsePluginEntitlement validates that the principal is the instance the plugin belongs to
+// and returns the instance ID, its instance API client and the plugin name.
+func (p *pluginResourceType) parsePluginEntitlement(
+	ctx context.Context,
+	principalID *v2.ResourceId,
+	entitlement *v2.Entitlement,
+) (int, *cloudamqp.InstanceClient, string, error) {
+	l := ctxzap.Extract(ctx)
+
+	if principalID.ResourceType != resourceTypeInstance.Id {
+		l.Warn(
+			"cloudamqp-connector: only instances can have plugins enabled",
+			zap.String("principal_id", principalID.String()),
+			zap.String("principal_type", principalID.ResourceType),
+		)
+
+		return 0, nil, "", fmt.Errorf("cloudamqp-connector: only instances can have plugins enabled")
+	}
+
+	instanceID, parts, err := parseInstanceScopedID(entitlement.Resource.Id.Resource, 1)
+	if err != nil {
+		return 0, nil, "", err
+	}
+
+	if principalID.Resource != instanceResourceID(instanceID).Resource {
+		return 0, nil, "", fmt.Errorf("cloudamqp-connector: plugin %s does not belong to instance %s", parts[0], principalID.Resource)
+	}
+
+	instanceClient, err := p.accounts.InstanceClient(ctx, instanceID)
+	if err != nil {
+		return 0, nil, "", fmt.Errorf("cloudamqp-connector: failed to get instance %d: %w", instanceID, err)
+	}
+
+	return instanceID, instanceClient, parts[0], nil
+}
+
+func pluginBuilder(accounts *accounts, lists *instanceLists, auditLog *AuditLog) *pluginResourceType {
+	return &pluginResourceType{
+		resourceType: resourceTypePlugin,
+		accounts:     accounts,
+		lists:        lists,
+		auditLog:     auditLog,
+	}
+}
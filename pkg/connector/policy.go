@@ -0,0 +1,222 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+)
+
+const policyModify = "modify"
+
+// Entitlements of vhosts held by broker users whose tags allow to change its policies or operator policies.
+const (
+	vhostManagePolicies         = "manage_policies"
+	vhostManageOperatorPolicies = "manage_operator_policies"
+)
+
+// Broker user tags allowing to change policies.
+const (
+	brokerTagAdministrator = "administrator"
+	brokerTagPolicymaker   = "policymaker"
+)
+
+// policyResourceType syncs policies or operator policies of vhosts. Policies can be changed by policymakers and
+// administrators having access to their vhost, operator policies by administrators only.
+type policyResourceType struct {
+	resourceType *v2.ResourceType
+	definitions  *brokerDefinitions
+	operator     bool
+}
+
+func (p *policyResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return p.resourceType
+}
+
+// policyResource creates a new connector resource for a policy in a virtual host of a CloudAMQP Instance. What the
+// policy applies to and the keys it defines are told in the description, as plain resources have no profile.
+func policyResource(instanceID int, resourceType *v2.ResourceType, policy *cloudamqp.Policy, description string) (*v2.Resource, error) {
+	resource, err := rs.NewResource(
+		policy.Name,
+		resourceType,
+		instanceScopedID(instanceID, policy.Vhost, policy.Name),
+		rs.WithParentResourceID(vhostResourceID(instanceID, policy.Vhost)),
+		rs.WithDescription(description),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+// policyDescription tells what a policy applies to and what it sets.
+func policyDescription(policy *cloudamqp.Policy) string {
+	keys := make([]string, 0, len(policy.Definition))
+	for key := range policy.Definition {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	applyTo := policy.ApplyTo
+	if applyTo == "" {
+		applyTo = "all"
+	}
+
+	return fmt.Sprintf(
+		"Applies to %s matching %s with priority %d, sets %s",
+		applyTo,
+		policy.Pattern,
+		policy.Priority,
+		strings.Join(keys, ", "),
+	)
+}
+
+func (p *policyResourceType) policies(definitions *cloudamqp.Definitions, vhost string) []cloudamqp.Policy {
+	if p.operator {
+		return definitions.VhostOperatorPolicies(vhost)
+	}
+
+	return definitions.VhostPolicies(vhost)
+}
+
+func (p *policyResourceType) List(ctx context.Context, parentID *v2.ResourceId, pt *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentID == nil || parentID.ResourceType != resourceTypeVhost.Id {
+		return nil, "", nil, nil
+	}
+
+	instanceID, parts, err := parseInstanceScopedID(parentID.Resource, 1)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	definitions, err := p.definitions.Get(ctx, instanceID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	policies := p.policies(definitions, parts[0])
+
+	rv := make([]*v2.Resource, 0, len(policies))
+	for _, policy := range policies {
+		policyCopy := policy
+
+		pr, err := policyResource(instanceID, p.resourceType, &policyCopy, p.definitions.readOnlyDescription(instanceID, policyDescription(&policyCopy)))
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rv = append(rv, pr)
+	}
+
+	return rv, "", nil, nil
+}
+
+// Entitlements returns the modify entitlement of the policy, held through the entitlement of its vhost managing
+// policies or operator policies.
+func (p *policyResourceType) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	managing := vhostManagePolicies
+	if p.operator {
+		managing = vhostManageOperatorPolicies
+	}
+
+	return []*v2.Entitlement{
+		ent.NewPermissionEntitlement(
+			resource,
+			policyModify,
+			ent.WithGrantableTo(resourceTypeBrokerUser),
+			ent.WithDisplayName(fmt.Sprintf("%s %s modify", resource.DisplayName, p.resourceType.DisplayName)),
+			ent.WithDescription(fmt.Sprintf(
+				"Can change or delete %s %s, held through the %s entitlement of its vhost",
+				strings.ToLower(p.resourceType.DisplayName),
+				resource.DisplayName,
+				managing,
+			)),
+		),
+	}, "", nil, nil
+}
+
+// Grants returns no grant: broker users change policies through the tags granting them the entitlements of the vhost
+// managing policies. The SDK version the connector is built with can not expand those grants onto policies.
+func (p *policyResourceType) Grants(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+// policyManagementEntitlements returns the entitlements of a vhost held by broker users allowed to change its policies
+// or operator policies.
+func policyManagementEntitlements(resource *v2.Resource) []*v2.Entitlement {
+	return []*v2.Entitlement{
+		ent.NewPermissionEntitlement(
+			resource,
+			vhostManagePolicies,
+			ent.WithGrantableTo(resourceTypeBrokerUser),
+			ent.WithDisplayName(fmt.Sprintf("%s vhost manage policies", resource.DisplayName)),
+			ent.WithDescription(fmt.Sprintf(
+				"Can change the policies of vhost %s, as a broker user tagged %s or %s with a permission in it",
+				resource.DisplayName,
+				brokerTagPolicymaker,
+				brokerTagAdministrator,
+			)),
+		),
+		ent.NewPermissionEntitlement(
+			resource,
+			vhostManageOperatorPolicies,
+			ent.WithGrantableTo(resourceTypeBrokerUser),
+			ent.WithDisplayName(fmt.Sprintf("%s vhost manage operator policies", resource.DisplayName)),
+			ent.WithDescription(fmt.Sprintf(
+				"Can change the operator policies of vhost %s, as a broker user tagged %s",
+				resource.DisplayName,
+				brokerTagAdministrator,
+			)),
+		),
+	}
+}
+
+// policyManagementGrants grants the entitlements of the vhost managing policies to the broker users whose tags allow
+// it: policymakers and administrators having a permission in the vhost change its policies, and administrators its
+// operator policies.
+func policyManagementGrants(instanceID int, resource *v2.Resource, definitions *cloudamqp.Definitions, vhost string) []*v2.Grant {
+	vhostAccess := make(map[string]bool)
+	for _, permission := range definitions.VhostPermissions(vhost) {
+		vhostAccess[permission.User] = true
+	}
+
+	var rv []*v2.Grant
+	for _, user := range definitions.Users {
+		administrator := containsString(user.Tags, brokerTagAdministrator)
+		policymaker := containsString(user.Tags, brokerTagPolicymaker)
+
+		if vhostAccess[user.Name] && (administrator || policymaker) {
+			rv = append(rv, grant.NewGrant(resource, vhostManagePolicies, brokerUserResourceID(instanceID, user.Name)))
+		}
+
+		if administrator {
+			rv = append(rv, grant.NewGrant(resource, vhostManageOperatorPolicies, brokerUserResourceID(instanceID, user.Name)))
+		}
+	}
+
+	return rv
+}
+
+func policyBuilder(definitions *brokerDefinitions) *policyResourceType {
+	return &policyResourceType{
+		resourceType: resourceTypePolicy,
+		definitions:  definitions,
+	}
+}
+
+func operatorPolicyBuilder(definitions *brokerDefinitions) *policyResourceType {
+	return &policyResourceType{
+		resourceType: resourceTypeOperatorPolicy,
+		definitions:  definitions,
+		operator:     true,
+	}
+}
@@ -0,0 +1,53 @@
+package connector
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Defaults for fetching broker data of several instances at once.
+const (
+	DefaultInstanceConcurrency = 4
+	DefaultInstanceTimeout     = 2 * time.Minute
+)
+
+// Prefetch exports definitions and limits of the brokers running on provided instances, fetching up to the configured
+// number of brokers at once. Brokers failing to be fetched are recorded as failed instead of failing the sync, and
+// are then synced without their data.
+func (b *brokerDefinitions) Prefetch(ctx context.Context, instanceIDs []int) {
+	workers := b.concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(instanceIDs) {
+		workers = len(instanceIDs)
+	}
+
+	queue := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for instanceID := range queue {
+				b.prefetch(ctx, instanceID)
+			}
+		}()
+	}
+
+	for _, instanceID := range instanceIDs {
+		queue <- instanceID
+	}
+	close(queue)
+
+	wg.Wait()
+}
+
+func (b *brokerDefinitions) prefetch(ctx context.Context, instanceID int) {
+	// Both tolerate the broker failing to be fetched, recording it as failed for the rest of the sync.
+	_, _ = b.Get(ctx, instanceID)
+	_, _ = b.Limits(ctx, instanceID)
+}
@@ -0,0 +1,94 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const protectedDescription = "Protected, exempt from provisioning changes"
+
+// ProtectedBrokerUser is a broker user provisioning must not change. A zero instance ID protects the username on
+// every instance.
+type ProtectedBrokerUser struct {
+	InstanceID int
+	Username   string
+}
+
+// protectedPrincipals are principals provisioning must never change, such as break-glass admins and automation
+// accounts. The default user of every broker is always protected, as the connector itself manages brokers with it.
+type protectedPrincipals struct {
+	accounts    *accounts
+	users       map[string]bool
+	brokerUsers map[ProtectedBrokerUser]bool
+}
+
+func newProtectedPrincipals(accounts *accounts, users []string, brokerUsers []ProtectedBrokerUser) *protectedPrincipals {
+	p := &protectedPrincipals{
+		accounts:    accounts,
+		users:       make(map[string]bool, len(users)),
+		brokerUsers: make(map[ProtectedBrokerUser]bool, len(brokerUsers)),
+	}
+
+	for _, user := range users {
+		p.users[strings.ToLower(user)] = true
+	}
+
+	for _, brokerUser := range brokerUsers {
+		p.brokerUsers[brokerUser] = true
+	}
+
+	return p
+}
+
+// IsUser tells whether provided team user is protected, by email or by ID.
+func (p *protectedPrincipals) IsUser(user *cloudamqp.User) bool {
+	return p.users[strings.ToLower(user.Email)] || p.users[strings.ToLower(user.Id)]
+}
+
+// CheckUser refuses changes to protected team users.
+func (p *protectedPrincipals) CheckUser(user *cloudamqp.User) error {
+	if p.IsUser(user) {
+		return status.Errorf(codes.PermissionDenied, "cloudamqp-connector: user %s is protected from provisioning changes", user.Email)
+	}
+
+	return nil
+}
+
+// IsBrokerUser tells whether provided user of the broker running on an instance is protected.
+func (p *protectedPrincipals) IsBrokerUser(ctx context.Context, instanceID int, username string) (bool, error) {
+	if p.brokerUsers[ProtectedBrokerUser{InstanceID: instanceID, Username: username}] ||
+		p.brokerUsers[ProtectedBrokerUser{Username: username}] {
+		return true, nil
+	}
+
+	defaultUser, err := p.accounts.InstanceDefaultUser(ctx, instanceID)
+	if err != nil {
+		return false, fmt.Errorf("cloudamqp-connector: failed to get instance %d: %w", instanceID, err)
+	}
+
+	return username == defaultUser, nil
+}
+
+// CheckBrokerUser refuses changes to protected broker users.
+func (p *protectedPrincipals) CheckBrokerUser(ctx context.Context, instanceID int, username string) error {
+	protected, err := p.IsBrokerUser(ctx, instanceID, username)
+	if err != nil {
+		return err
+	}
+
+	if protected {
+		return status.Errorf(
+			codes.PermissionDenied,
+			"cloudamqp-connector: broker user %s of instance %d is protected from provisioning changes",
+			username,
+			instanceID,
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package connector
+
+import (
+	"context"
+
+	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+)
+
+type queueResourceType struct {
+	resourceType *v2.ResourceType
+	definitions  *brokerDefinitions
+}
+
+func (q *queueResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return q.resourceType
+}
+
+// queueResource creates a new connector resource for a queue in a virtual host of a CloudAMQP Instance.
+func queueResource(instanceID int, queue *cloudamqp.Queue, description string) (*v2.Resource, error) {
+	resource, err := rs.NewResource(
+		queue.Name,
+		resourceTypeQueue,
+		instanceScopedID(instanceID, queue.Vhost, queue.Name),
+		rs.WithParentResourceID(vhostResourceID(instanceID, queue.Vhost)),
+		rs.WithDescription(description),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+func (q *queueResourceType) List(ctx context.Context, parentID *v2.ResourceId, pt *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentID == nil || parentID.ResourceType != resourceTypeVhost.Id {
+		return nil, "", nil, nil
+	}
+
+	instanceID, parts, err := parseInstanceScopedID(parentID.Resource, 1)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	definitions, err := q.definitions.Get(ctx, instanceID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	queues := definitions.VhostQueues(parts[0])
+
+	rv := make([]*v2.Resource, 0, len(queues))
+	for _, queue := range queues {
+		queueCopy := queue
+
+		qr, err := queueResource(instanceID, &queueCopy, q.definitions.readOnlyDescription(instanceID, ""))
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rv = append(rv, qr)
+	}
+
+	return rv, "", nil, nil
+}
+
+func (q *queueResourceType) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	return permissionEntitlements(resource, "queue"), "", nil, nil
+}
+
+// Grants returns the effective access of broker users to the queue, computed from their vhost permission patterns.
+func (q *queueResourceType) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	instanceID, parts, err := parseInstanceScopedID(resource.Id.Resource, 2)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	definitions, err := q.definitions.Get(ctx, instanceID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return effectivePermissionGrants(ctx, instanceID, resource, parts[1], definitions.VhostPermissions(parts[0]), nil), "", nil, nil
+}
+
+func queueBuilder(definitions *brokerDefinitions) *queueResourceType {
+	return &queueResourceType{
+		resourceType: resourceTypeQueue,
+		definitions:  definitions,
+	}
+}
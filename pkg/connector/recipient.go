@@ -0,0 +1,116 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+)
+
+const recipientTypeEmail = "email"
+
+type alarmRecipientResourceType struct {
+	resourceType *v2.ResourceType
+	accounts     *accounts
+}
+
+func (a *alarmRecipientResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return a.resourceType
+}
+
+// alarmRecipientResource creates a new connector resource for a notification recipient of a CloudAMQP Instance.
+// Email recipients are people, every other recipient (Slack, PagerDuty, webhooks, ...) is a service account.
+func alarmRecipientResource(instanceID int, recipient *cloudamqp.AlarmRecipient) (*v2.Resource, error) {
+	displayName := recipient.Name
+	if displayName == "" {
+		displayName = recipient.Value
+	}
+
+	profile := map[string]interface{}{
+		"recipient_id": recipient.Id,
+		"type":         recipient.Type,
+		"value":        recipient.Value,
+	}
+
+	traitOptions := []rs.UserTraitOption{
+		rs.WithUserProfile(profile),
+		rs.WithStatus(v2.UserTrait_Status_STATUS_ENABLED),
+	}
+
+	if recipient.Type == recipientTypeEmail {
+		traitOptions = append(traitOptions,
+			rs.WithEmail(recipient.Value, true),
+			rs.WithAccountType(v2.UserTrait_ACCOUNT_TYPE_HUMAN),
+		)
+	} else {
+		traitOptions = append(traitOptions, rs.WithAccountType(v2.UserTrait_ACCOUNT_TYPE_SERVICE))
+	}
+
+	resource, err := rs.NewUserResource(
+		displayName,
+		resourceTypeAlarmRecipient,
+		instanceScopedID(instanceID, strconv.Itoa(recipient.Id)),
+		traitOptions,
+		rs.WithParentResourceID(instanceResourceID(instanceID)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+func (a *alarmRecipientResourceType) List(ctx context.Context, parentID *v2.ResourceId, pt *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentID == nil || parentID.ResourceType != resourceTypeInstance.Id {
+		return nil, "", nil, nil
+	}
+
+	instanceID, err := parseInstanceID(parentID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	instanceClient, err := a.accounts.InstanceClient(ctx, instanceID)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("cloudamqp-connector: failed to get instance %d: %w", instanceID, err)
+	}
+
+	recipients, err := instanceClient.GetAlarmRecipients(ctx)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("cloudamqp-connector: failed to list alarm recipients of instance %d: %w", instanceID, err)
+	}
+
+	rv := make([]*v2.Resource, 0, len(recipients))
+	for _, recipient := range recipients {
+		recipientCopy := recipient
+
+		rr, err := alarmRecipientResource(instanceID, &recipientCopy)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rv = append(rv, rr)
+	}
+
+	return rv, "", nil, nil
+}
+
+func (a *alarmRecipientResourceType) Entitlements(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+func (a *alarmRecipientResourceType) Grants(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+func alarmRecipientBuilder(accounts *accounts) *alarmRecipientResourceType {
+	return &alarmRecipientResourceType{
+		resourceType: resourceTypeAlarmRecipient,
+		accounts:     accounts,
+	}
+}
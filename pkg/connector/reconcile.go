@@ -0,0 +1,208 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+)
+
+// Changes a team reconciliation plan can make.
+const (
+	TeamChangeInvite     = "invite"
+	TeamChangeUpdateRole = "update_role"
+	TeamChangeRemove     = "remove"
+)
+
+// TeamState is the intended membership of the team account.
+type TeamState struct {
+	Users []TeamMember `json:"users" yaml:"users"`
+}
+
+type TeamMember struct {
+	Email string `json:"email" yaml:"email"`
+	Role  string `json:"role" yaml:"role"`
+}
+
+// TeamChange is a single change bringing the team account closer to its intended membership.
+type TeamChange struct {
+	Action      string `json:"action"`
+	Email       string `json:"email"`
+	UserID      string `json:"user_id,omitempty"`
+	CurrentRole string `json:"current_role,omitempty"`
+	Role        string `json:"role,omitempty"`
+}
+
+func (c *TeamChange) String() string {
+	switch c.Action {
+	case TeamChangeInvite:
+		return fmt.Sprintf("invite %s as %s", c.Email, c.Role)
+	case TeamChangeUpdateRole:
+		return fmt.Sprintf("change role of %s from %s to %s", c.Email, c.CurrentRole, c.Role)
+	case TeamChangeRemove:
+		return fmt.Sprintf("remove %s (%s)", c.Email, c.CurrentRole)
+	default:
+		return fmt.Sprintf("%s %s", c.Action, c.Email)
+	}
+}
+
+// TeamPlan lists the changes needed for the team account to match its intended membership.
+type TeamPlan struct {
+	Changes []TeamChange `json:"changes"`
+	// Protected lists changes left out of the plan because they concern protected users.
+	Protected []TeamChange `json:"protected,omitempty"`
+}
+
+// Validate checks that every member of the state is listed once, with a known role.
+func (s *TeamState) Validate() error {
+	seen := make(map[string]bool, len(s.Users))
+	for _, member := range s.Users {
+		email := strings.ToLower(strings.TrimSpace(member.Email))
+		if email == "" {
+			return fmt.Errorf("cloudamqp-connector: team member without email")
+		}
+
+		if seen[email] {
+			return fmt.Errorf("cloudamqp-connector: team member %s is listed more than once", member.Email)
+		}
+		seen[email] = true
+
+		if !containsString(teamAccessRoles, member.Role) {
+			return fmt.Errorf("cloudamqp-connector: team member %s has unknown role %q", member.Email, member.Role)
+		}
+	}
+
+	return nil
+}
+
+// PlanTeam diffs the intended membership against the users of the team account. Users missing from the state
+// are removed, members missing from the account are invited by their lowercased and trimmed email, and members with
+// another role have it updated. Changes to provided protected users, by email or ID, are never planned.
+func PlanTeam(ctx context.Context, client *cloudamqp.Client, state *TeamState, protectedUsers []string) (*TeamPlan, error) {
+	if err := state.Validate(); err != nil {
+		return nil, err
+	}
+
+	users, err := client.GetUsers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cloudamqp-connector: failed to get users: %w", err)
+	}
+
+	current := make(map[string]cloudamqp.User, len(users))
+	for _, user := range users {
+		current[strings.ToLower(user.Email)] = user
+	}
+
+	plan := &TeamPlan{}
+	desired := make(map[string]bool, len(state.Users))
+	for _, member := range state.Users {
+		email := strings.ToLower(strings.TrimSpace(member.Email))
+		desired[email] = true
+
+		user, ok := current[email]
+		if !ok {
+			plan.Changes = append(plan.Changes, TeamChange{
+				Action: TeamChangeInvite,
+				Email:  email,
+				Role:   member.Role,
+			})
+
+			continue
+		}
+
+		if len(user.Roles) != 1 || user.Roles[0] != member.Role {
+			plan.Changes = append(plan.Changes, TeamChange{
+				Action:      TeamChangeUpdateRole,
+				Email:       user.Email,
+				UserID:      user.Id,
+				CurrentRole: strings.Join(user.Roles, ","),
+				Role:        member.Role,
+			})
+		}
+	}
+
+	for _, user := range users {
+		if desired[strings.ToLower(user.Email)] {
+			continue
+		}
+
+		plan.Changes = append(plan.Changes, TeamChange{
+			Action:      TeamChangeRemove,
+			Email:       user.Email,
+			UserID:      user.Id,
+			CurrentRole: strings.Join(user.Roles, ","),
+		})
+	}
+
+	protected := newProtectedPrincipals(nil, protectedUsers, nil)
+	changes := plan.Changes[:0]
+	for _, change := range plan.Changes {
+		if protected.IsUser(&cloudamqp.User{BaseResource: cloudamqp.BaseResource{Id: change.UserID}, Email: change.Email}) {
+			plan.Protected = append(plan.Protected, change)
+			continue
+		}
+
+		changes = append(changes, change)
+	}
+	plan.Changes = changes
+
+	sort.SliceStable(plan.Changes, func(i, j int) bool {
+		return plan.Changes[i].Email < plan.Changes[j].Email
+	})
+
+	return plan, nil
+}
+
+// ApplyTeamPlan makes the changes of provided plan, stopping at the first one failing. Changes are recorded in
+// the audit log when one is provided.
+func ApplyTeamPlan(ctx context.Context, client *cloudamqp.Client, plan *TeamPlan, auditLog *AuditLog) error {
+	for _, change := range plan.Changes {
+		change := change
+
+		err := auditLog.track(ctx, teamAuditChange(&change), func() error {
+			switch change.Action {
+			case TeamChangeInvite:
+				return client.InviteUser(ctx, change.Email, change.Role)
+			case TeamChangeUpdateRole:
+				return client.UpdateUserRole(ctx, change.UserID, change.Role)
+			case TeamChangeRemove:
+				return client.RemoveUser(ctx, change.UserID)
+			default:
+				return fmt.Errorf("unknown action %s", change.Action)
+			}
+		})
+
+		if err != nil {
+			return fmt.Errorf("cloudamqp-connector: failed to %s: %w", change.String(), err)
+		}
+	}
+
+	return nil
+}
+
+func teamAuditChange(change *TeamChange) *auditChange {
+	principal := change.Email
+	if change.UserID != "" {
+		principal = auditPrincipal(&v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: change.UserID})
+	}
+
+	rv := &auditChange{
+		operation: auditOperationReconcile,
+		action:    change.Action,
+		principal: principal,
+		target:    change.Email,
+	}
+
+	if change.CurrentRole != "" {
+		rv.before = map[string]string{"email": change.Email, "role": change.CurrentRole}
+	}
+
+	if change.Action != TeamChangeRemove {
+		rv.after = map[string]string{"email": change.Email, "role": change.Role}
+	}
+
+	return rv
+}
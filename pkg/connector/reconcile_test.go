@@ -0,0 +1,141 @@
+package connector
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
+)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// stubClient returns a client answering GET requests of provided URLs with provided JSON bodies.
+func stubClient(t *testing.T, bodies map[string]string) *cloudamqp.Client {
+	t.Helper()
+
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body, ok := bodies[req.URL.String()]
+		if !ok || req.Method != http.MethodGet {
+			t.Errorf("unexpected request %s %s", req.Method, req.URL)
+			return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}}, nil
+		}
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+		}, nil
+	})
+
+	return cloudamqp.NewClient(&http.Client{Transport: transport}, "token")
+}
+
+func TestPlanTeam(t *testing.T) {
+	users := `[
+		{"id": "1", "email": "ada@example.com", "roles": ["admin"]},
+		{"id": "2", "email": "Bob@example.com", "roles": ["member"]},
+		{"id": "3", "email": "carol@example.com", "roles": ["monitor"]},
+		{"id": "4", "email": "dave@example.com", "roles": ["devops"]}
+	]`
+
+	tests := []struct {
+		name      string
+		members   []TeamMember
+		protected []string
+		changes   []string
+		skipped   []string
+	}{
+		{
+			name: "in sync, emails are case insensitive",
+			members: []TeamMember{
+				{Email: "ada@example.com", Role: roleAdmin},
+				{Email: "bob@example.com", Role: roleMember},
+				{Email: "carol@example.com", Role: roleMonitor},
+				{Email: " dave@example.com ", Role: roleDevops},
+			},
+		},
+		{
+			name: "invite, update role and remove",
+			members: []TeamMember{
+				{Email: "ada@example.com", Role: roleAdmin},
+				{Email: "bob@example.com", Role: roleDevops},
+				{Email: " Erin@Example.com ", Role: roleMonitor},
+				{Email: "dave@example.com", Role: roleDevops},
+			},
+			changes: []string{
+				"change role of Bob@example.com from member to devops",
+				"remove carol@example.com (monitor)",
+				"invite erin@example.com as monitor",
+			},
+		},
+		{
+			name: "protected users by email and id",
+			members: []TeamMember{
+				{Email: "bob@example.com", Role: roleMember},
+			},
+			protected: []string{"ada@example.com", "4"},
+			changes:   []string{"remove carol@example.com (monitor)"},
+			skipped:   []string{"remove ada@example.com (admin)", "remove dave@example.com (devops)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := stubClient(t, map[string]string{cloudamqp.UsersBaseURL: users})
+
+			plan, err := PlanTeam(context.Background(), client, &TeamState{Users: tt.members}, tt.protected)
+			if err != nil {
+				t.Fatalf("PlanTeam() error = %v", err)
+			}
+
+			if got := teamChangeStrings(plan.Changes); !reflect.DeepEqual(got, tt.changes) {
+				t.Fatalf("PlanTeam() changes = %q, want %q", got, tt.changes)
+			}
+
+			if got := teamChangeStrings(plan.Protected); !reflect.DeepEqual(got, tt.skipped) {
+				t.Fatalf("PlanTeam() protected = %q, want %q", got, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestPlanTeamInvalidState(t *testing.T) {
+	tests := []struct {
+		name    string
+		members []TeamMember
+	}{
+		{name: "missing email", members: []TeamMember{{Email: " ", Role: roleAdmin}}},
+		{name: "unknown role", members: []TeamMember{{Email: "ada@example.com", Role: "owner"}}},
+		{name: "duplicate member", members: []TeamMember{
+			{Email: "ada@example.com", Role: roleAdmin},
+			{Email: "ADA@example.com", Role: roleMember},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := stubClient(t, nil)
+
+			if _, err := PlanTeam(context.Background(), client, &TeamState{Users: tt.members}, nil); err == nil {
+				t.Fatal("PlanTeam() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func teamChangeStrings(changes []TeamChange) []string {
+	var rv []string
+	for _, change := range changes {
+		rv = append(rv, change.String())
+	}
+
+	return rv
+}
@@ -3,6 +3,8 @@ package connector
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
@@ -28,28 +30,60 @@ var teamAccessRoles = []string{
 	roleAdmin, roleDevops, roleMember, roleMonitor, roleBillingManager, roleComplianceManager,
 }
 
+// impliedRoles are the roles whose access is included in a role, not counting the ones they imply themselves:
+// admin can do everything devops can, and devops has the visibility of monitor.
+var impliedRoles = map[string][]string{
+	roleAdmin:  {roleDevops},
+	roleDevops: {roleMonitor},
+}
+
+// rolesImpliedBy returns every role whose access is included in provided role, following the hierarchy.
+func rolesImpliedBy(role string) []string {
+	var rv []string
+	for _, implied := range impliedRoles[role] {
+		rv = append(rv, implied)
+		rv = append(rv, rolesImpliedBy(implied)...)
+	}
+
+	return rv
+}
+
 type roleResourceType struct {
 	resourceType *v2.ResourceType
-	client       *cloudamqp.Client
+	accounts     *accounts
+	protected    *protectedPrincipals
+	auditLog     *AuditLog
 }
 
 func (r *roleResourceType) ResourceType(_ context.Context) *v2.ResourceType {
 	return r.resourceType
 }
 
-// roleResource creates a new connector resource for a CloudAMQP Role.
-func roleResource(role string) (*v2.Resource, error) {
+// roleResource creates a new connector resource for a CloudAMQP Role of provided account.
+func roleResource(accounts *accounts, acc *account, role string) (*v2.Resource, error) {
 	displayName := titleCase(role)
+	implied := make([]interface{}, 0, len(impliedRoles[role]))
+	for _, impliedRole := range rolesImpliedBy(role) {
+		implied = append(implied, impliedRole)
+	}
+
 	profile := map[string]interface{}{
-		"role_id":   role,
-		"role_name": displayName,
+		"role_id":       role,
+		"role_name":     displayName,
+		"implied_roles": implied,
+	}
+
+	options := []rs.ResourceOption{
+		rs.WithParentResourceID(accounts.ResourceID(acc)),
+		rs.WithDescription(roleDescription(role)),
 	}
 
 	resource, err := rs.NewRoleResource(
 		displayName,
 		resourceTypeRole,
-		role,
+		accounts.ScopedID(acc, role),
 		[]rs.RoleTraitOption{rs.WithRoleProfile(profile)},
+		options...,
 	)
 	if err != nil {
 		return nil, err
@@ -58,16 +92,60 @@ func roleResource(role string) (*v2.Resource, error) {
 	return resource, nil
 }
 
+// roleHierarchyFingerprint describes the role hierarchy, which changes the grants computed from the same team users.
+func roleHierarchyFingerprint() string {
+	roles := make([]string, 0, len(impliedRoles))
+	for role := range impliedRoles {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
+	parts := make([]string, 0, len(roles))
+	for _, role := range roles {
+		parts = append(parts, role+">"+strings.Join(impliedRoles[role], ","))
+	}
+
+	return strings.Join(parts, ";")
+}
+
+// roleAccess tells what each role allows on the team account.
+var roleAccess = map[string]string{
+	roleAdmin:             "Full access to the team account: manages team members and their roles, billing, VPCs and instances",
+	roleDevops:            "Creates, configures and deletes instances and VPCs, along with their alarms, integrations, plugins and firewall rules, without access to team members or billing",
+	roleMember:            "Views and configures the instances of the team account, without creating or deleting them, and without access to team members or billing",
+	roleMonitor:           "Read-only access to the instances of the team account: views their configuration, metrics, logs and alarms without changing them",
+	roleBillingManager:    "Manages billing of the team account: payment methods, invoices and billing contacts, without access to instances",
+	roleComplianceManager: "Views the audit log and security settings of the team account and its instances for compliance reviews, without changing them",
+}
+
+// roleDescription tells what a role allows, and which lower roles its access includes, if any.
+func roleDescription(role string) string {
+	description := roleAccess[role]
+
+	implied := rolesImpliedBy(role)
+	if len(implied) == 0 {
+		return description
+	}
+
+	return fmt.Sprintf("%s. Includes the access of the %s roles", description, strings.Join(implied, " and "))
+}
+
 func (r *roleResourceType) List(ctx context.Context, parentID *v2.ResourceId, pt *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
-	rv := make([]*v2.Resource, 0, len(teamAccessRoles))
+	accounts, err := r.accounts.ForParent(parentID)
+	if err != nil {
+		return nil, "", nil, err
+	}
 
-	for _, role := range teamAccessRoles {
-		rr, err := roleResource(role)
-		if err != nil {
-			return nil, "", nil, err
-		}
+	var rv []*v2.Resource
+	for _, acc := range accounts {
+		for _, role := range teamAccessRoles {
+			rr, err := roleResource(r.accounts, acc, role)
+			if err != nil {
+				return nil, "", nil, err
+			}
 
-		rv = append(rv, rr)
+			rv = append(rv, rr)
+		}
 	}
 
 	return rv, "", nil, nil
@@ -76,10 +154,15 @@ func (r *roleResourceType) List(ctx context.Context, parentID *v2.ResourceId, pt
 func (r *roleResourceType) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
 	var rv []*v2.Entitlement
 
+	description := fmt.Sprintf("%s CloudAMQP role", resource.DisplayName)
+	if resource.Description != "" {
+		description = fmt.Sprintf("%s: %s", description, resource.Description)
+	}
+
 	entitlementOptions := []ent.EntitlementOption{
 		ent.WithGrantableTo(resourceTypeUser),
 		ent.WithDisplayName(fmt.Sprintf("%s role", resource.DisplayName)),
-		ent.WithDescription(fmt.Sprintf("%s CloudAMQP role", resource.DisplayName)),
+		ent.WithDescription(description),
 	}
 
 	rv = append(rv, ent.NewAssignmentEntitlement(resource, roleMember, entitlementOptions...))
@@ -87,23 +170,36 @@ func (r *roleResourceType) Entitlements(_ context.Context, resource *v2.Resource
 	return rv, "", nil, nil
 }
 
+// Grants returns the users holding the role. Users holding a higher role are not granted the roles it implies: the SDK
+// version the connector is built with can not expand grants, and such grants could not be revoked on their own.
+// When the team has not changed since the previous sync, its grants are reused instead.
 func (r *roleResourceType) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
-	users, err := r.client.GetUsers(ctx)
+	acc, roleId, err := r.accounts.ParseScopedID(resource.Id.Resource)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	users, err := acc.client.GetUsers(ctx)
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("cloudamqp-connector: failed to get users: %w", err)
 	}
 
+	annos, unchanged := grantsETag(resource, roleMember, acc.client.UsersVersion(), r.accounts.Fingerprint(), roleHierarchyFingerprint())
+	if unchanged {
+		return nil, "", annos, nil
+	}
+
 	var rv []*v2.Grant
 	for _, user := range users {
 		userCopy := user
 
-		ur, err := userResource(ctx, &userCopy)
+		ur, err := r.accounts.userResource(ctx, acc, &userCopy, r.protected.IsUser(&userCopy))
 		if err != nil {
 			return nil, "", nil, fmt.Errorf("cloudamqp-connector: failed to build user resource: %w", err)
 		}
 
 		for _, role := range user.Roles {
-			if role == resource.Id.Resource {
+			if role == roleId {
 				rv = append(rv, grant.NewGrant(
 					resource,
 					roleMember,
@@ -113,7 +209,7 @@ func (r *roleResourceType) Grants(ctx context.Context, resource *v2.Resource, _
 		}
 	}
 
-	return rv, "", nil, nil
+	return rv, "", annos, nil
 }
 
 func (r *roleResourceType) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) (annotations.Annotations, error) {
@@ -129,10 +225,18 @@ func (r *roleResourceType) Grant(ctx context.Context, principal *v2.Resource, en
 		return nil, fmt.Errorf("cloudamqp-connector: only users can be granted roles")
 	}
 
-	userId, roleId := principal.Id.Resource, entitlement.Resource.Id.Resource
-	err := r.client.UpdateUserRole(ctx, userId, roleId)
+	acc, roleId, err := r.accounts.ParseScopedID(entitlement.Resource.Id.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	if !containsString(teamAccessRoles, roleId) {
+		return nil, fmt.Errorf("cloudamqp-connector: unknown role %s", roleId)
+	}
+
+	err = r.updateUserRole(ctx, auditOperationGrant, entitlement.Id, acc, principal.Id.Resource, roleId)
 	if err != nil {
-		return nil, fmt.Errorf("cloudamqp-connector: failed to update user role: %w", err)
+		return nil, err
 	}
 
 	return nil, nil
@@ -154,18 +258,95 @@ func (r *roleResourceType) Revoke(ctx context.Context, grant *v2.Grant) (annotat
 		return nil, fmt.Errorf("cloudamqp-connector: only users can have roles revoked")
 	}
 
-	userId, roleId := principal.Id.Resource, roleMember
-	err := r.client.UpdateUserRole(ctx, userId, roleId)
+	acc, _, err := r.accounts.ParseScopedID(grant.Entitlement.Resource.Id.Resource)
 	if err != nil {
-		return nil, fmt.Errorf("cloudamqp-connector: failed to update user role: %w", err)
+		return nil, err
+	}
+
+	err = r.updateUserRole(ctx, auditOperationRevoke, grant.Entitlement.Id, acc, principal.Id.Resource, roleMember)
+	if err != nil {
+		return nil, err
 	}
 
 	return nil, nil
 }
 
-func roleBuilder(client *cloudamqp.Client) *roleResourceType {
+// updateUserRole gives provided role to the user of an account, unless it is the only role the user already has.
+func (r *roleResourceType) updateUserRole(
+	ctx context.Context,
+	operation string,
+	entitlementId string,
+	acc *account,
+	principalId string,
+	roleId string,
+) error {
+	l := ctxzap.Extract(ctx)
+
+	userAcc, userId, err := r.accounts.ParseScopedID(principalId)
+	if err != nil {
+		return err
+	}
+
+	if userAcc != acc {
+		return fmt.Errorf("cloudamqp-connector: user %s is not a member of account %s", principalId, acc.name)
+	}
+
+	users, err := acc.client.GetUsers(ctx)
+	if err != nil {
+		return fmt.Errorf("cloudamqp-connector: failed to get users: %w", err)
+	}
+
+	var user *cloudamqp.User
+	for i := range users {
+		if users[i].Id == userId {
+			user = &users[i]
+			break
+		}
+	}
+
+	if user == nil {
+		return fmt.Errorf("cloudamqp-connector: user %s is not a member of the team", userId)
+	}
+
+	err = r.protected.CheckUser(user)
+	if err != nil {
+		return err
+	}
+
+	if len(user.Roles) == 1 && user.Roles[0] == roleId {
+		l.Info(
+			"cloudamqp-connector: user already has role",
+			zap.String("user_id", userId),
+			zap.String("role", roleId),
+		)
+
+		return nil
+	}
+
+	change := &auditChange{
+		operation: operation,
+		action:    "update_user_role",
+		principal: auditPrincipal(&v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: principalId}),
+		target:    entitlementId,
+		before:    user,
+		after:     &cloudamqp.User{BaseResource: user.BaseResource, Email: user.Email, Roles: []string{roleId}},
+	}
+
+	err = r.auditLog.track(ctx, change, func() error {
+		return acc.client.UpdateUserRole(ctx, userId, roleId)
+	})
+	if err != nil {
+		return fmt.Errorf("cloudamqp-connector: failed to update user role: %w", err)
+	}
+
+	return nil
+}
+
+func roleBuilder(accounts *accounts, protected *protectedPrincipals, auditLog *AuditLog) *roleResourceType {
 	return &roleResourceType{
 		resourceType: resourceTypeRole,
-		client:       client,
+		accounts:     accounts,
+		protected:    protected,
+		auditLog:     auditLog,
 	}
 }
@@ -0,0 +1,21 @@
+package connector
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRoleDescriptions(t *testing.T) {
+	for _, role := range teamAccessRoles {
+		description := roleDescription(role)
+		if roleAccess[role] == "" || !strings.HasPrefix(description, roleAccess[role]) {
+			t.Errorf("role %s is described as %q, which does not tell what it allows", role, description)
+		}
+
+		for _, implied := range rolesImpliedBy(role) {
+			if !strings.Contains(description, implied) {
+				t.Errorf("description of role %s does not mention implied role %s: %q", role, implied, description)
+			}
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+)
+
+type shovelResourceType struct {
+	resourceType *v2.ResourceType
+	definitions  *brokerDefinitions
+}
+
+func (s *shovelResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return s.resourceType
+}
+
+// shovelResource creates a new non-human identity for a shovel in a virtual host of a CloudAMQP Instance.
+// The profile records which brokers and users the shovel connects as, and whether it embeds passwords. Broker users of
+// synced brokers it authenticates as grant it their connect entitlement.
+func shovelResource(instanceID int, shovel *cloudamqp.Shovel, sources []*amqpEndpoint, destinations []*amqpEndpoint) (*v2.Resource, error) {
+	sourcesProfile, sourcesInlinePassword := endpointsProfile(sources)
+	destinationsProfile, destinationsInlinePassword := endpointsProfile(destinations)
+
+	profile := map[string]interface{}{
+		"name":                 shovel.Name,
+		"vhost":                shovel.Vhost,
+		"source_queue":         shovel.SrcQueue,
+		"source_exchange":      shovel.SrcExchange,
+		"destination_queue":    shovel.DestQueue,
+		"destination_exchange": shovel.DestExchange,
+		"sources":              sourcesProfile,
+		"destinations":         destinationsProfile,
+		"inline_password":      sourcesInlinePassword || destinationsInlinePassword,
+	}
+
+	resource, err := rs.NewUserResource(
+		shovel.Name,
+		resourceTypeShovel,
+		instanceScopedID(instanceID, shovel.Vhost, shovel.Name),
+		[]rs.UserTraitOption{
+			rs.WithUserProfile(profile),
+			rs.WithStatus(v2.UserTrait_Status_STATUS_ENABLED),
+			rs.WithAccountType(v2.UserTrait_ACCOUNT_TYPE_SERVICE),
+		},
+		rs.WithParentResourceID(vhostResourceID(instanceID, shovel.Vhost)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+func (s *shovelResourceType) List(ctx context.Context, parentID *v2.ResourceId, pt *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentID == nil || parentID.ResourceType != resourceTypeVhost.Id {
+		return nil, "", nil, nil
+	}
+
+	instanceID, parts, err := parseInstanceScopedID(parentID.Resource, 1)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	definitions, err := s.definitions.Get(ctx, instanceID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	shovels, err := definitions.VhostShovels(parts[0])
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("cloudamqp-connector: failed to list shovels of vhost %s on instance %d: %w", parts[0], instanceID, err)
+	}
+
+	if len(shovels) == 0 {
+		return nil, "", nil, nil
+	}
+
+	resolver, err := newAMQPEndpointResolver(ctx, s.definitions.accounts, instanceID)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("cloudamqp-connector: failed to get instance %d: %w", instanceID, err)
+	}
+
+	rv := make([]*v2.Resource, 0, len(shovels))
+	for _, shovel := range shovels {
+		shovelCopy := shovel
+
+		sr, err := shovelResource(
+			instanceID,
+			&shovelCopy,
+			resolver.Resolve(ctx, shovel.SrcURIs),
+			resolver.Resolve(ctx, shovel.DestURIs),
+		)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rv = append(rv, sr)
+	}
+
+	return rv, "", nil, nil
+}
+
+func (s *shovelResourceType) Entitlements(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+// Grants returns the connect entitlements of the broker users the shovel authenticates as.
+func (s *shovelResourceType) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	instanceID, parts, err := parseInstanceScopedID(resource.Id.Resource, 2)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	definitions, err := s.definitions.Get(ctx, instanceID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	shovels, err := definitions.VhostShovels(parts[0])
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("cloudamqp-connector: failed to list shovels of vhost %s on instance %d: %w", parts[0], instanceID, err)
+	}
+
+	for _, shovel := range shovels {
+		if shovel.Name != parts[1] {
+			continue
+		}
+
+		resolver, err := newAMQPEndpointResolver(ctx, s.definitions.accounts, instanceID)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("cloudamqp-connector: failed to get instance %d: %w", instanceID, err)
+		}
+
+		endpoints := append(resolver.Resolve(ctx, shovel.SrcURIs), resolver.Resolve(ctx, shovel.DestURIs)...)
+
+		return endpointGrants(resource, endpoints), "", nil, nil
+	}
+
+	return nil, "", nil, nil
+}
+
+func shovelBuilder(definitions *brokerDefinitions) *shovelResourceType {
+	return &shovelResourceType{
+		resourceType: resourceTypeShovel,
+		definitions:  definitions,
+	}
+}
@@ -13,29 +13,38 @@ import (
 
 type userResourceType struct {
 	resourceType *v2.ResourceType
-	client       *cloudamqp.Client
+	accounts     *accounts
+	protected    *protectedPrincipals
 }
 
 func (u *userResourceType) ResourceType(_ context.Context) *v2.ResourceType {
 	return u.resourceType
 }
 
-// Create a new connector resource for a CloudAMQP User.
-func userResource(ctx context.Context, user *cloudamqp.User) (*v2.Resource, error) {
+// Create a new connector resource for a CloudAMQP User. Protected users are marked so that reviewers can tell
+// they are exempt from provisioning changes.
+func userResource(ctx context.Context, user *cloudamqp.User, id string, parentID *v2.ResourceId, protected bool) (*v2.Resource, error) {
 	profile := map[string]interface{}{
-		"login":   user.Email,
-		"user_id": user.Id,
+		"login":     user.Email,
+		"user_id":   user.Id,
+		"protected": protected,
+	}
+
+	resourceOptions := []resource.ResourceOption{resource.WithParentResourceID(parentID)}
+	if protected {
+		resourceOptions = append(resourceOptions, resource.WithDescription(protectedDescription))
 	}
 
 	ret, err := resource.NewUserResource(
 		user.Email,
 		resourceTypeUser,
-		user.Id,
+		id,
 		[]resource.UserTraitOption{
 			resource.WithEmail(user.Email, true),
 			resource.WithUserProfile(profile),
 			resource.WithStatus(v2.UserTrait_Status_STATUS_ENABLED),
 		},
+		resourceOptions...,
 	)
 	if err != nil {
 		return nil, err
@@ -45,21 +54,28 @@ func userResource(ctx context.Context, user *cloudamqp.User) (*v2.Resource, erro
 }
 
 func (u *userResourceType) List(ctx context.Context, parentID *v2.ResourceId, pt *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
-	users, err := u.client.GetUsers(ctx)
+	accounts, err := u.accounts.ForParent(parentID)
 	if err != nil {
-		return nil, "", nil, fmt.Errorf("cloudamqp-connector: failed to list users: %w", err)
+		return nil, "", nil, err
 	}
 
-	rv := make([]*v2.Resource, 0, len(users))
-	for _, user := range users {
-		userCopy := user
-
-		ur, err := userResource(ctx, &userCopy)
+	var rv []*v2.Resource
+	for _, acc := range accounts {
+		users, err := acc.client.GetUsers(ctx)
 		if err != nil {
-			return nil, "", nil, err
+			return nil, "", nil, fmt.Errorf("cloudamqp-connector: failed to list users: %w", err)
 		}
 
-		rv = append(rv, ur)
+		for _, user := range users {
+			userCopy := user
+
+			ur, err := u.accounts.userResource(ctx, acc, &userCopy, u.protected.IsUser(&userCopy))
+			if err != nil {
+				return nil, "", nil, err
+			}
+
+			rv = append(rv, ur)
+		}
 	}
 
 	return rv, "", nil, nil
@@ -73,9 +89,10 @@ func (u *userResourceType) Grants(_ context.Context, _ *v2.Resource, _ *paginati
 	return nil, "", nil, nil
 }
 
-func userBuilder(client *cloudamqp.Client) *userResourceType {
+func userBuilder(accounts *accounts, protected *protectedPrincipals) *userResourceType {
 	return &userResourceType{
 		resourceType: resourceTypeUser,
-		client:       client,
+		accounts:     accounts,
+		protected:    protected,
 	}
 }
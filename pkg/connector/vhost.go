@@ -0,0 +1,253 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+)
+
+var vhostChildResourceTypes = []*v2.ResourceType{
+	resourceTypeQueue,
+	resourceTypeExchange,
+	resourceTypePolicy,
+	resourceTypeOperatorPolicy,
+	resourceTypeShovel,
+	resourceTypeFederationUpstream,
+}
+
+type vhostResourceType struct {
+	resourceType  *v2.ResourceType
+	definitions   *brokerDefinitions
+	auditLog      *AuditLog
+	defaultLimits cloudamqp.Limits
+}
+
+func (v *vhostResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return v.resourceType
+}
+
+// vhostResource creates a new connector resource for a virtual host of the broker running on a CloudAMQP Instance.
+// Vhosts have no trait to keep a profile in, so their limits are told in the description when the broker supports them.
+func vhostResource(instanceID int, vhost *cloudamqp.Vhost, description string, limits *brokerLimits) (*v2.Resource, error) {
+	if limits != nil {
+		description = limitsDescription(description, limits.vhosts[vhost.Name])
+	}
+
+	resourceOptions := []rs.ResourceOption{
+		rs.WithParentResourceID(instanceResourceID(instanceID)),
+		rs.WithDescription(description),
+	}
+	for _, childResourceType := range vhostChildResourceTypes {
+		resourceOptions = append(resourceOptions, rs.WithAnnotation(&v2.ChildResourceType{ResourceTypeId: childResourceType.Id}))
+	}
+
+	resource, err := rs.NewResource(
+		vhost.Name,
+		resourceTypeVhost,
+		instanceScopedID(instanceID, vhost.Name),
+		resourceOptions...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+func (v *vhostResourceType) List(ctx context.Context, parentID *v2.ResourceId, pt *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentID == nil || parentID.ResourceType != resourceTypeInstance.Id {
+		return nil, "", nil, nil
+	}
+
+	instanceID, err := parseInstanceID(parentID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	definitions, err := v.definitions.Get(ctx, instanceID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	limits, err := v.definitions.Limits(ctx, instanceID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	rv := make([]*v2.Resource, 0, len(definitions.Vhosts))
+	for _, vhost := range definitions.Vhosts {
+		vhostCopy := vhost
+
+		vr, err := vhostResource(instanceID, &vhostCopy, v.definitions.readOnlyDescription(instanceID, vhost.Description), limits)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rv = append(rv, vr)
+	}
+
+	return rv, "", nil, nil
+}
+
+// Entitlements returns permission entitlements, the entitlements managing policies and the unlimited entitlement, held
+// by the vhost while no limit is set on it. Vhosts have no trait, so ConductorOne can not request the unlimited entitlement for them: it is synced and can
+// be revoked, and granted with a baton grant task only.
+func (v *vhostResourceType) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	rv := append(permissionEntitlements(resource, "vhost"), unlimitedEntitlementFor(resource, resourceTypeVhost, "vhost"))
+	return append(rv, policyManagementEntitlements(resource)...), "", nil, nil
+}
+
+// Grants returns the raw permissions of broker users in the vhost, with their patterns as provenance, and the broker
+// users whose tags allow to change its policies.
+func (v *vhostResourceType) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	instanceID, parts, err := parseInstanceScopedID(resource.Id.Resource, 1)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	definitions, err := v.definitions.Get(ctx, instanceID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	limits, err := v.definitions.Limits(ctx, instanceID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	var rv []*v2.Grant
+	if limits != nil {
+		rv = append(rv, unlimitedGrant(resource, limits.vhosts[parts[0]])...)
+	}
+
+	for _, permission := range definitions.VhostPermissions(parts[0]) {
+		permissionCopy := permission
+
+		for _, kind := range brokerPermissions {
+			if permissionPattern(&permissionCopy, kind) != "" {
+				rv = append(rv, permissionGrant(instanceID, resource, kind, &permissionCopy, ""))
+			}
+		}
+	}
+
+	return append(rv, policyManagementGrants(instanceID, resource, definitions, parts[0])...), "", nil, nil
+}
+
+// Grant removes every limit of the vhost. Permissions of broker users are only synced.
+func (v *vhostResourceType) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) (annotations.Annotations, error) {
+	if entitlement.Slug != unlimitedEntitlement {
+		return nil, fmt.Errorf("cloudamqp-connector: only the unlimited entitlement of vhosts can be provisioned")
+	}
+
+	err := v.updateLimits(ctx, auditOperationGrant, principal.Id, entitlement, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// Revoke sets the default limits back on the vhost.
+func (v *vhostResourceType) Revoke(ctx context.Context, g *v2.Grant) (annotations.Annotations, error) {
+	if g.Entitlement.Slug != unlimitedEntitlement {
+		return nil, fmt.Errorf("cloudamqp-connector: only the unlimited entitlement of vhosts can be provisioned")
+	}
+
+	if len(v.defaultLimits) == 0 {
+		return nil, fmt.Errorf("cloudamqp-connector: no default vhost limits are configured")
+	}
+
+	err := v.updateLimits(ctx, auditOperationRevoke, g.Principal.Id, g.Entitlement, v.defaultLimits)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// updateLimits replaces the limits of the vhost with provided ones.
+func (v *vhostResourceType) updateLimits(
+	ctx context.Context,
+	operation string,
+	principalID *v2.ResourceId,
+	entitlement *v2.Entitlement,
+	limits cloudamqp.Limits,
+) error {
+	err := checkUnlimitedPrincipal(principalID, entitlement)
+	if err != nil {
+		return err
+	}
+
+	instanceID, parts, err := parseInstanceScopedID(principalID.Resource, 1)
+	if err != nil {
+		return err
+	}
+	vhost := parts[0]
+
+	if v.definitions.ReadOnly(instanceID) {
+		return fmt.Errorf("cloudamqp-connector: instance %d is synced from a definitions file and can not be changed", instanceID)
+	}
+
+	brokerClient, err := broker(ctx, v.definitions.accounts, instanceID)
+	if err != nil {
+		return err
+	}
+
+	current, err := v.definitions.fetchLimits(ctx, instanceID)
+	if err != nil {
+		return err
+	}
+
+	if current == nil {
+		return fmt.Errorf("cloudamqp-connector: broker of instance %d does not support vhost limits", instanceID)
+	}
+
+	change := &auditChange{
+		operation: operation,
+		action:    "update_vhost_limits",
+		principal: auditPrincipal(principalID),
+		target:    entitlement.Id,
+		before:    current.vhosts[vhost],
+		after:     limits,
+	}
+
+	setter := &limitsSetter{
+		set: func(ctx context.Context, name string, value int64) error {
+			return brokerClient.SetVhostLimit(ctx, vhost, name, value)
+		},
+		clear: func(ctx context.Context, name string) error {
+			return brokerClient.ClearVhostLimit(ctx, vhost, name)
+		},
+	}
+
+	err = v.auditLog.track(ctx, change, func() error {
+		return setter.applyLimits(ctx, current.vhosts[vhost], limits)
+	})
+	v.definitions.InvalidateLimits(instanceID)
+	if err != nil {
+		return fmt.Errorf("cloudamqp-connector: failed to update limits of vhost %s: %w", vhost, err)
+	}
+
+	return nil
+}
+
+func vhostResourceID(instanceID int, vhost string) *v2.ResourceId {
+	return &v2.ResourceId{
+		ResourceType: resourceTypeVhost.Id,
+		Resource:     instanceScopedID(instanceID, vhost),
+	}
+}
+
+func vhostBuilder(definitions *brokerDefinitions, auditLog *AuditLog, defaultLimits cloudamqp.Limits) *vhostResourceType {
+	return &vhostResourceType{
+		resourceType:  resourceTypeVhost,
+		definitions:   definitions,
+		auditLog:      auditLog,
+		defaultLimits: defaultLimits,
+	}
+}
@@ -0,0 +1,113 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/conductorone/baton-cloudamqp/pkg/cloudamqp"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+)
+
+type vpcResourceType struct {
+	resourceType *v2.ResourceType
+	accounts     *accounts
+}
+
+func (v *vpcResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return v.resourceType
+}
+
+// vpcResource creates a new connector resource for a CloudAMQP VPC.
+// Peering connections and CIDR ranges allowed to reach the VPC are kept in the profile.
+func vpcResource(accounts *accounts, acc *account, vpc *cloudamqp.Vpc, peerings []cloudamqp.VpcPeering) (*v2.Resource, error) {
+	allowedCidrs := []string{vpc.Subnet}
+	peeringProfiles := make([]interface{}, 0, len(peerings))
+	for _, peering := range peerings {
+		peeringProfiles = append(peeringProfiles, map[string]interface{}{
+			"peering_id":    peering.Id,
+			"status":        peering.Status,
+			"peer_vpc_id":   peering.PeerVpcId,
+			"peer_owner_id": peering.PeerOwnerId,
+			"peer_region":   peering.PeerRegion,
+			"peer_subnets":  toInterfaceSlice(peering.PeerSubnets),
+		})
+
+		allowedCidrs = append(allowedCidrs, peering.PeerSubnets...)
+	}
+
+	profile := map[string]interface{}{
+		"vpc_id":        vpc.Id,
+		"vpc_name":      vpc.VpcName,
+		"region":        vpc.Region,
+		"subnet":        vpc.Subnet,
+		"tags":          toInterfaceSlice(vpc.Tags),
+		"peerings":      peeringProfiles,
+		"allowed_cidrs": toInterfaceSlice(allowedCidrs),
+	}
+
+	resource, err := rs.NewGroupResource(
+		vpc.Name,
+		resourceTypeVpc,
+		accounts.ScopedID(acc, strconv.Itoa(vpc.Id)),
+		[]rs.GroupTraitOption{rs.WithGroupProfile(profile)},
+		rs.WithParentResourceID(accounts.ResourceID(acc)),
+		rs.WithAnnotation(&v2.ChildResourceType{ResourceTypeId: resourceTypeInstance.Id}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+func (v *vpcResourceType) List(ctx context.Context, parentID *v2.ResourceId, pt *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	accounts, err := v.accounts.ForParent(parentID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	var rv []*v2.Resource
+	for _, acc := range accounts {
+		vpcs, err := acc.client.GetVpcs(ctx)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("cloudamqp-connector: failed to list vpcs: %w", err)
+		}
+
+		for _, vpc := range vpcs {
+			vpcCopy := vpc
+
+			peerings, err := acc.client.GetVpcPeerings(ctx, vpc.Id)
+			if err != nil {
+				return nil, "", nil, fmt.Errorf("cloudamqp-connector: failed to list peerings of vpc %d: %w", vpc.Id, err)
+			}
+
+			vr, err := vpcResource(v.accounts, acc, &vpcCopy, peerings)
+			if err != nil {
+				return nil, "", nil, err
+			}
+
+			rv = append(rv, vr)
+		}
+	}
+
+	return rv, "", nil, nil
+}
+
+func (v *vpcResourceType) Entitlements(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+func (v *vpcResourceType) Grants(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+func vpcBuilder(accounts *accounts) *vpcResourceType {
+	return &vpcResourceType{
+		resourceType: resourceTypeVpc,
+		accounts:     accounts,
+	}
+}